@@ -1,31 +1,134 @@
 package client
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
 	"github.com/patrickmn/go-cache"
 	"github.com/prometheus/common/log"
+	"golang.org/x/sync/singleflight"
 )
 
-// NewCachedClient returns a new cached client
-func NewCachedClient(client Client, cache *cache.Cache) Client {
+// NewCachedClient returns a new cached client. Clients sharing a cache must
+// have different namespaces when the same repository means something else to
+// each of them, so they don't get each other's results.
+func NewCachedClient(client Client, cache *cache.Cache, namespace string) Client {
 	return cachedClient{
-		client: client,
-		cache:  cache,
+		client:    client,
+		cache:     cache,
+		namespace: namespace,
+		group:     &singleflight.Group{},
 	}
 }
 
+// HashHeaders returns a short hash of the given headers, to tell apart the
+// namespaces of clients sending different ones without exposing their values.
+func HashHeaders(headers map[string]string) string {
+	var names = make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var hash = sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s=%s\n", name, headers[name])
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)[:8])
+}
+
+// cachedClient caches the results of the underlying client and makes sure
+// concurrent identical requests share a single upstream call.
 type cachedClient struct {
-	client Client
-	cache  *cache.Cache
+	client    Client
+	cache     *cache.Cache
+	namespace string
+	group     *singleflight.Group
 }
 
 func (c cachedClient) Releases(repo string) ([]Release, error) {
-	cached, found := c.cache.Get(repo)
+	var key = c.key("", repo)
+	cached, found := c.cache.Get(key)
 	if found {
 		log.Debugf("using result from cache for %s", repo)
 		return cached.([]Release), nil
 	}
-	log.Debugf("using result from API for %s", repo)
-	live, err := c.client.Releases(repo)
-	c.cache.Set(repo, live, cache.DefaultExpiration)
-	return live, err
+	live, err, _ := c.group.Do(key, func() (interface{}, error) {
+		log.Debugf("using result from API for %s", repo)
+		live, err := c.client.Releases(repo)
+		c.set(key, live, err)
+		return live, err
+	})
+	return live.([]Release), err
+}
+
+func (c cachedClient) Tags(repo string) ([]Tag, error) {
+	var key = c.key("tags:", repo)
+	cached, found := c.cache.Get(key)
+	if found {
+		log.Debugf("using tags from cache for %s", repo)
+		return cached.([]Tag), nil
+	}
+	live, err, _ := c.group.Do(key, func() (interface{}, error) {
+		log.Debugf("using tags from API for %s", repo)
+		live, err := c.client.Tags(repo)
+		c.set(key, live, err)
+		return live, err
+	})
+	return live.([]Tag), err
+}
+
+func (c cachedClient) Changelog(repo string) (string, error) {
+	var key = c.key("changelog:", repo)
+	cached, found := c.cache.Get(key)
+	if found {
+		log.Debugf("using changelog from cache for %s", repo)
+		return cached.(string), nil
+	}
+	live, err, _ := c.group.Do(key, func() (interface{}, error) {
+		log.Debugf("using changelog from API for %s", repo)
+		live, err := c.client.Changelog(repo)
+		c.set(key, live, err)
+		return live, err
+	})
+	return live.(string), err
+}
+
+// key returns the cache key of the given kind of result of the repository.
+func (c cachedClient) key(kind, repo string) string {
+	if c.namespace == "" {
+		return kind + repo
+	}
+	return c.namespace + "|" + kind + repo
+}
+
+// set caches the result, unless getting it failed, e.g. because of rate
+// limits or maintenance mode, so it is fetched again on the next collect.
+func (c cachedClient) set(key string, result interface{}, err error) {
+	if err != nil {
+		return
+	}
+	c.cache.Set(key, result, cache.DefaultExpiration)
+}
+
+// Prefetch gets at once the releases of the given repositories which are not
+// cached yet, when the underlying client supports it.
+func (c cachedClient) Prefetch(repos []string) {
+	batcher, ok := c.client.(Batcher)
+	if !ok {
+		return
+	}
+	var missing []string
+	for _, repo := range repos {
+		if _, found := c.cache.Get(c.key("", repo)); !found {
+			missing = append(missing, repo)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	log.Debugf("prefetching releases of %d repositories", len(missing))
+	for repo, releases := range batcher.ReleasesBatch(missing) {
+		c.cache.Set(c.key("", repo), releases, cache.DefaultExpiration)
+	}
 }
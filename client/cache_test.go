@@ -1,10 +1,13 @@
 package client
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -15,7 +18,7 @@ func TestCachedClient(t *testing.T) {
 			TagName: "v1.1.1",
 		},
 	}
-	var cli = NewCachedClient(cacheTestClient{result: &rel}, c)
+	var cli = NewCachedClient(cacheTestClient{result: &rel}, c, "")
 	var oldRel = rel
 
 	t.Run("get fresh", func(t *testing.T) {
@@ -37,6 +40,146 @@ func TestCachedClient(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, rel, res)
 	})
+
+	t.Run("tags cached apart from releases", func(t *testing.T) {
+		res, err := cli.Tags("foo")
+		require.NoError(t, err)
+		require.Len(t, res, len(rel))
+		rel = append(rel, Release{TagName: "2"})
+		res, err = cli.Tags("foo")
+		require.NoError(t, err)
+		require.Len(t, res, len(rel)-1)
+	})
+}
+
+func TestCachedClientNamespace(t *testing.T) {
+	var c = cache.New(1*time.Minute, 1*time.Minute)
+	var foo = []Release{{TagName: "v1.0.0"}}
+	var bar = []Release{{TagName: "v2.0.0"}}
+	var fooCli = NewCachedClient(cacheTestClient{result: &foo}, c, "foo")
+	var barCli = NewCachedClient(cacheTestClient{result: &bar}, c, "bar")
+
+	res, err := fooCli.Releases("repo")
+	require.NoError(t, err)
+	require.Equal(t, foo, res)
+	res, err = barCli.Releases("repo")
+	require.NoError(t, err)
+	require.Equal(t, bar, res)
+}
+
+func TestCachedClientError(t *testing.T) {
+	var c = cache.New(1*time.Minute, 1*time.Minute)
+	var upstream = &failingTestClient{err: ErrRateLimited}
+	var cli = NewCachedClient(upstream, c, "")
+
+	_, err := cli.Releases("foo")
+	require.Equal(t, ErrRateLimited, err)
+
+	upstream.err = nil
+	res, err := cli.Releases("foo")
+	require.NoError(t, err)
+	require.Equal(t, []Release{{TagName: "v1.0.0"}}, res)
+	require.Equal(t, 2, upstream.calls, "should not have cached the error")
+}
+
+func TestCachedClientConcurrentRequests(t *testing.T) {
+	var c = cache.New(1*time.Minute, 1*time.Minute)
+	var upstream = &blockingTestClient{release: make(chan struct{})}
+	var cli = NewCachedClient(upstream, c, "")
+
+	// the upstream call blocks until all goroutines are calling, the ones
+	// coming too late for it are served from the cache anyway
+	var wg, calling sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		calling.Add(1)
+		go func() {
+			defer wg.Done()
+			calling.Done()
+			res, err := cli.Releases("foo")
+			assert.NoError(t, err)
+			assert.Len(t, res, 1)
+		}()
+	}
+	calling.Wait()
+	close(upstream.release)
+	wg.Wait()
+	require.Equal(t, int32(1), atomic.LoadInt32(&upstream.calls))
+}
+
+func TestCachedClientPrefetch(t *testing.T) {
+	var c = cache.New(1*time.Minute, 1*time.Minute)
+	var upstream = &batchTestClient{}
+	var cli = NewCachedClient(upstream, c, "")
+	c.Set("foo/cached", []Release{{TagName: "v0.1.0"}}, cache.DefaultExpiration)
+
+	cli.(Prefetcher).Prefetch([]string{"foo/bar", "foo/cached", "foo/gone"})
+	require.Equal(t, [][]string{{"foo/bar", "foo/gone"}}, upstream.batches)
+
+	res, err := cli.Releases("foo/bar")
+	require.NoError(t, err)
+	require.Equal(t, []Release{{TagName: "v1.0.0"}}, res)
+	require.Zero(t, upstream.calls, "should have been prefetched")
+
+	_, err = cli.Releases("foo/gone")
+	require.NoError(t, err)
+	require.Equal(t, 1, upstream.calls, "should fall back to releases")
+}
+
+// batchTestClient batches all repositories but foo/gone.
+type batchTestClient struct {
+	blockingTestClient
+	batches [][]string
+	calls   int
+}
+
+func (f *batchTestClient) Releases(repo string) ([]Release, error) {
+	f.calls++
+	return nil, nil
+}
+
+func (f *batchTestClient) ReleasesBatch(repos []string) map[string][]Release {
+	f.batches = append(f.batches, repos)
+	var result = map[string][]Release{}
+	for _, repo := range repos {
+		if repo != "foo/gone" {
+			result[repo] = []Release{{TagName: "v1.0.0"}}
+		}
+	}
+	return result
+}
+
+type blockingTestClient struct {
+	release chan struct{}
+	calls   int32
+}
+
+func (f *blockingTestClient) Releases(repo string) ([]Release, error) {
+	atomic.AddInt32(&f.calls, 1)
+	<-f.release
+	return []Release{{TagName: "v1.0.0"}}, nil
+}
+
+func (f *blockingTestClient) Tags(repo string) ([]Tag, error) {
+	return nil, nil
+}
+
+func (f *blockingTestClient) Changelog(repo string) (string, error) {
+	return "", nil
+}
+
+type failingTestClient struct {
+	cacheTestClient
+	err   error
+	calls int
+}
+
+func (f *failingTestClient) Releases(repo string) ([]Release, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []Release{{TagName: "v1.0.0"}}, nil
 }
 
 type cacheTestClient struct {
@@ -46,3 +189,23 @@ type cacheTestClient struct {
 func (f cacheTestClient) Releases(repo string) ([]Release, error) {
 	return *f.result, nil
 }
+
+func (f cacheTestClient) Tags(repo string) ([]Tag, error) {
+	var tags []Tag
+	for _, release := range *f.result {
+		tags = append(tags, Tag{Name: release.TagName})
+	}
+	return tags, nil
+}
+
+func (f cacheTestClient) Changelog(repo string) (string, error) {
+	return "", nil
+}
+
+func TestHashHeaders(t *testing.T) {
+	var hash = HashHeaders(map[string]string{"A": "1", "B": "2"})
+	require.Len(t, hash, 16)
+	require.Equal(t, hash, HashHeaders(map[string]string{"B": "2", "A": "1"}))
+	require.NotEqual(t, hash, HashHeaders(map[string]string{"A": "1", "B": "3"}))
+	require.NotEqual(t, hash, HashHeaders(nil))
+}
@@ -5,13 +5,34 @@ import "time"
 // Release from github api
 type Release struct {
 	TagName     string    `json:"tag_name,omitempty"`
+	Name        string    `json:"name,omitempty"`
 	Draft       bool      `json:"draft,omitempty"`
 	Prerelease  bool      `json:"prerelease,omitempty"`
 	PublishedAt time.Time `json:"published_at,omitempty"`
+	Body        string    `json:"body,omitempty"`
+	Assets      []Asset   `json:"assets,omitempty"`
+}
+
+// Asset of a release from github api
+type Asset struct {
+	Name          string `json:"name,omitempty"`
+	DownloadCount int    `json:"download_count,omitempty"`
+}
+
+// Tag from github api
+type Tag struct {
+	Name string `json:"name,omitempty"`
 }
 
 // Client a client
 type Client interface {
 	// Releases returns all releases for a given repository
 	Releases(repo string) ([]Release, error)
+
+	// Tags returns all tags for a given repository
+	Tags(repo string) ([]Tag, error)
+
+	// Changelog returns the CHANGELOG.md of the default branch of a given
+	// repository
+	Changelog(repo string) (string, error)
 }
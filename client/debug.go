@@ -0,0 +1,59 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/prometheus/common/log"
+)
+
+// debugBody logs up to size bytes of a failed response body, at debug level.
+// It does not close the body.
+func debugBody(url string, body io.Reader, size int) {
+	if size <= 0 {
+		return
+	}
+	bts, err := ioutil.ReadAll(io.LimitReader(body, int64(size)+1))
+	if err != nil {
+		log.With("url", url).Debugf("failed to read response body: %s", err)
+		return
+	}
+	var truncated = len(bts) > size
+	if truncated {
+		bts = bts[:size]
+	}
+	log.With("url", url).
+		With("truncated", truncated).
+		Debugf("response body: %s", bts)
+}
+
+// teeBody returns a reader of the body which keeps up to size bytes of what
+// is read from it, and a function logging them like debugBody. Nothing is
+// kept when size is 0, which it is when not in debug mode.
+func teeBody(body io.Reader, size int) (io.Reader, func(url string)) {
+	if size <= 0 {
+		return body, func(string) {}
+	}
+	var kept = &limitedBuffer{max: size + 1}
+	return io.TeeReader(body, kept), func(url string) {
+		debugBody(url, bytes.NewReader(kept.Bytes()), size)
+	}
+}
+
+// limitedBuffer keeps the first max bytes written to it, ignoring the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		if len(p) > room {
+			_, _ = b.Buffer.Write(p[:room])
+		} else {
+			_, _ = b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
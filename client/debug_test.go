@@ -0,0 +1,23 @@
+package client
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeeBodyDisabled(t *testing.T) {
+	var body = strings.NewReader(`{"foo":"bar"}`)
+	reader, _ := teeBody(body, 0)
+	require.Equal(t, body, reader, "should not keep anything")
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	var kept = &limitedBuffer{max: 5}
+	_, err := ioutil.ReadAll(io.TeeReader(strings.NewReader(`{"foo":"bar"}`), kept))
+	require.NoError(t, err)
+	require.Equal(t, `{"foo`, kept.String())
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NewExecClient returns a new client that runs the given command with the
+// repository as its only argument, and reads a single version from its
+// standard output.
+//
+// The command is never run through a shell, and it is up to the caller to
+// make sure it is an allowed one.
+func NewExecClient(command string, timeout time.Duration) Client {
+	return execClient{
+		command: command,
+		timeout: timeout,
+	}
+}
+
+type execClient struct {
+	command string
+	timeout time.Duration
+}
+
+func (c execClient) Releases(repo string) ([]Release, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, c.command, repo).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to run %s", c.command)
+	}
+	var version = strings.TrimSpace(string(out))
+	if version == "" {
+		return nil, errors.Errorf("%s did not output a version", c.command)
+	}
+	return []Release{{TagName: version}}, nil
+}
+
+func (c execClient) Tags(repo string) ([]Tag, error) {
+	releases, err := c.Releases(repo)
+	if err != nil {
+		return nil, err
+	}
+	return []Tag{{Name: releases[0].TagName}}, nil
+}
+
+func (c execClient) Changelog(repo string) (string, error) {
+	return "", errors.New("the exec provider has no changelog")
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecClient(t *testing.T) {
+	releases, err := NewExecClient("echo", time.Second).Releases("v1.2.3")
+	require.NoError(t, err)
+	require.Equal(t, []Release{{TagName: "v1.2.3"}}, releases)
+
+	tags, err := NewExecClient("echo", time.Second).Tags("v1.2.3")
+	require.NoError(t, err)
+	require.Equal(t, []Tag{{Name: "v1.2.3"}}, tags)
+}
+
+func TestExecClientEmptyOutput(t *testing.T) {
+	_, err := NewExecClient("true", time.Second).Releases("foo")
+	require.EqualError(t, err, "true did not output a version")
+}
+
+func TestExecClientFailure(t *testing.T) {
+	_, err := NewExecClient("false", time.Second).Releases("foo")
+	require.Error(t, err)
+}
+
+func TestExecClientTimeout(t *testing.T) {
+	_, err := NewExecClient("sleep", 10*time.Millisecond).Releases("1")
+	require.Error(t, err)
+}
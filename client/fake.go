@@ -1,5 +1,7 @@
 package client
 
+import "fmt"
+
 // NewFakeClient returns a new fake client
 func NewFakeClient(result []Release, err error) Client {
 	return fakeClient{
@@ -16,3 +18,19 @@ type fakeClient struct {
 func (f fakeClient) Releases(repo string) ([]Release, error) {
 	return f.result, f.err
 }
+
+func (f fakeClient) Tags(repo string) ([]Tag, error) {
+	var tags = make([]Tag, 0, len(f.result))
+	for _, release := range f.result {
+		tags = append(tags, Tag{Name: release.TagName})
+	}
+	return tags, f.err
+}
+
+func (f fakeClient) Changelog(repo string) (string, error) {
+	var changelog = "# Changelog\n"
+	for _, release := range f.result {
+		changelog += fmt.Sprintf("\n## [%s]\n", release.TagName)
+	}
+	return changelog, f.err
+}
@@ -3,42 +3,278 @@ package client
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/log"
 )
 
+// Rate limit behaviors
+const (
+	RateLimitFail = "fail"
+	RateLimitWait = "wait"
+)
+
+// ErrRateLimited is returned when github rate limited a request
+var ErrRateLimited = errors.New("rate_limited")
+
+// nolint: gochecknoglobals
+var (
+	rateLimitWaits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "version_github_ratelimit_waits_total",
+		Help: "Total number of times the GitHub API rate limit was waited for",
+	})
+	rateLimitWaitSeconds = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "version_github_ratelimit_wait_seconds_total",
+		Help: "Total time spent waiting for the GitHub API rate limit to reset",
+	})
+	rateLimitUsed = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "version_github_ratelimit_used_ratio",
+		Help: "Ratio of the GitHub REST API rate limit used, from 0 to 1, according to the last response",
+	})
+	repoMoved = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "version_repo_moved",
+		Help: "Repositories that were renamed or transferred, and where to",
+	}, []string{"from", "to"})
+)
+
+// GithubOptions configures the github client
+type GithubOptions struct {
+	Token string
+
+	// OnRateLimit is either RateLimitFail or RateLimitWait
+	OnRateLimit string
+
+	// MaxWait caps how long to wait for the rate limit to reset
+	MaxWait time.Duration
+
+	// Limiter caps the concurrent requests to each host, nil means no limit
+	Limiter *HostLimiter
+
+	// DebugBodySize is how much of the response body to log on errors, at
+	// debug level
+	DebugBodySize int
+
+	// MaxRedirects caps how many redirects are followed, 0 means none
+	MaxRedirects int
+
+	// GraphQL enables getting the releases of many repositories at once
+	// with the GraphQL API, which requires a token
+	GraphQL bool
+}
+
 // NewClient returns a new github client
-func NewClient(token string) Client {
-	return githubClient{
-		token: token,
+func NewClient(opts GithubOptions) Client {
+	var client = githubClient{
+		baseURL: "https://api.github.com",
+		opts:    opts,
+		client:  newHTTPClient(0, opts.MaxRedirects, opts.Limiter),
+	}
+	if opts.GraphQL && opts.Token == "" {
+		// every batch would fail and fall back to the rest api anyway
+		log.Warn("the github graphql api requires a token, not using it")
+		return client
 	}
+	if opts.GraphQL {
+		return graphqlClient{client}
+	}
+	return client
 }
 
 type githubClient struct {
-	token string
+	baseURL string
+	opts    GithubOptions
+	client  *http.Client
 }
 
 func (c githubClient) Releases(repo string) ([]Release, error) {
 	var releases []Release
-	req, _ := http.NewRequest(
-		http.MethodGet,
-		fmt.Sprintf("https://api.github.com/repos/%s/releases", repo),
-		nil,
+	redirected, err := c.get(fmt.Sprintf("%s/repos/%s/releases", c.baseURL, repo), &releases)
+	if err != nil {
+		return releases, errors.Wrap(err, "failed to get repository releases")
+	}
+	if redirected {
+		c.moved(repo)
+	}
+	return releases, nil
+}
+
+func (c githubClient) Tags(repo string) ([]Tag, error) {
+	var tags []Tag
+	redirected, err := c.get(fmt.Sprintf("%s/repos/%s/tags", c.baseURL, repo), &tags)
+	if err != nil {
+		return tags, errors.Wrap(err, "failed to get repository tags")
+	}
+	if redirected {
+		c.moved(repo)
+	}
+	return tags, nil
+}
+
+func (c githubClient) Changelog(repo string) (string, error) {
+	resp, err := c.fetch(
+		fmt.Sprintf("%s/repos/%s/contents/CHANGELOG.md", c.baseURL, repo),
+		"application/vnd.github.v3.raw",
 	)
-	if c.token != "" {
-		req.Header.Add("Authorization", fmt.Sprintf("token %s", c.token))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get repository changelog")
 	}
-	resp, err := http.DefaultClient.Do(req)
+	defer resp.Body.Close()
+	bts, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return releases, errors.Wrap(err, "failed to get repository releases")
+		return "", errors.Wrap(err, "failed to read repository changelog")
+	}
+	return string(bts), nil
+}
+
+// moved logs and exposes that the repository was renamed or transferred.
+// GitHub keeps redirecting requests to its new location, so collecting it
+// still works until the config is updated.
+func (c githubClient) moved(repo string) {
+	var log = log.With("repo", repo)
+	var info struct {
+		FullName string `json:"full_name"`
+	}
+	if _, err := c.get(fmt.Sprintf("%s/repos/%s", c.baseURL, repo), &info); err != nil {
+		log.Warnf("repository moved, but failed to find where: %s", err)
+		return
+	}
+	movedTo(repo, info.FullName)
+}
+
+// movedTo logs and exposes where the repository moved to.
+func movedTo(repo, to string) {
+	log.With("repo", repo).Warnf("repository moved to %s, please update the config", to)
+	repoMoved.WithLabelValues(repo, to).Set(1)
+}
+
+// ResetMoved forgets the moved repositories, so the ones which were updated
+// or removed in the config are not exposed anymore. The others are found
+// again on their next fetch.
+func ResetMoved() {
+	repoMoved.Reset()
+}
+
+// get decodes the response of the given url into result, and reports whether
+// the request was redirected.
+func (c githubClient) get(url string, result interface{}) (bool, error) {
+	resp, err := c.fetch(url, "")
+	if err != nil {
+		return false, err
 	}
 	defer resp.Body.Close()
+	body, debug := teeBody(resp.Body, c.opts.DebugBodySize)
+	if err := json.NewDecoder(body).Decode(result); err != nil {
+		debug(url)
+		return false, errors.Wrap(err, "failed to parse the response body")
+	}
+	return resp.Request.URL.String() != url, nil
+}
+
+// fetch requests the given url, waiting for the rate limit to reset if
+// configured to, and returns the response if it succeeded. The caller must
+// close its body.
+func (c githubClient) fetch(url, accept string) (*http.Response, error) {
+	resp, err := c.do(url, accept)
+	if err != nil {
+		return nil, err
+	}
+	if wait, limited := rateLimited(resp); limited {
+		resp.Body.Close()
+		if c.opts.OnRateLimit != RateLimitWait {
+			return nil, ErrRateLimited
+		}
+		if wait > c.opts.MaxWait {
+			wait = c.opts.MaxWait
+		}
+		log.With("url", url).Warnf("rate limited, waiting %s before retrying", wait)
+		time.Sleep(wait)
+		rateLimitWaits.Inc()
+		rateLimitWaitSeconds.Add(wait.Seconds())
+		if resp, err = c.do(url, accept); err != nil {
+			return nil, err
+		}
+		if _, limited := rateLimited(resp); limited {
+			resp.Body.Close()
+			return nil, ErrRateLimited
+		}
+	}
 	if resp.StatusCode != http.StatusOK {
-		return releases, errors.Wrap(err, "github responded a non-200 status code")
+		debugBody(url, resp.Body, c.opts.DebugBodySize)
+		resp.Body.Close()
+		return nil, errors.Errorf("github responded a non-200 status code: %d", resp.StatusCode)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
-		return releases, errors.Wrap(err, "failed to parse the response body")
+	return resp, nil
+}
+
+// do sends a rest api request. GraphQL api requests have a rate limit of
+// their own, so only these ones update the used rate limit ratio.
+func (c githubClient) do(url, accept string) (*http.Response, error) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if accept != "" {
+		req.Header.Add("Accept", accept)
 	}
-	return releases, nil
+	resp, err := c.send(req)
+	if err != nil {
+		return resp, err
+	}
+	if ratio, ok := rateLimitUsedRatio(resp); ok {
+		rateLimitUsed.Set(ratio)
+	}
+	return resp, nil
+}
+
+// send authenticates and sends the request.
+func (c githubClient) send(req *http.Request) (*http.Response, error) {
+	if c.opts.Token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("token %s", c.opts.Token))
+	}
+	var client = c.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// rateLimitUsedRatio returns how much of the rate limit was used, according
+// to the X-RateLimit-Limit and X-RateLimit-Remaining headers, if present.
+func rateLimitUsedRatio(resp *http.Response) (float64, bool) {
+	limit, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Limit"), 64)
+	if err != nil || limit <= 0 {
+		return 0, false
+	}
+	remaining, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Remaining"), 64)
+	if err != nil {
+		return 0, false
+	}
+	return (limit - remaining) / limit, true
+}
+
+// rateLimited reports whether the response is a rate limit error and how
+// long to wait before the limit resets, according to the Retry-After or
+// X-RateLimit-Reset headers.
+func rateLimited(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, true
+	}
+	var wait = time.Until(time.Unix(reset, 0))
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
 }
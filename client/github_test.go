@@ -0,0 +1,155 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGithubReleases(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/foo/bar/releases", r.URL.Path)
+		assert.Equal(t, "token secret", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `[{"tag_name":"v1.0.0","name":"first"}]`)
+	}))
+	defer srv.Close()
+
+	var cli = githubClient{baseURL: srv.URL, opts: GithubOptions{Token: "secret"}}
+	releases, err := cli.Releases("foo/bar")
+	require.NoError(t, err)
+	require.Equal(t, []Release{{TagName: "v1.0.0", Name: "first"}}, releases)
+}
+
+func TestGithubNon200(t *testing.T) {
+	var srv = httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	var cli = githubClient{baseURL: srv.URL}
+	_, err := cli.Tags("foo/bar")
+	require.EqualError(t, err, "failed to get repository tags: github responded a non-200 status code: 404")
+}
+
+func TestGithubRateLimit(t *testing.T) {
+	var calls int32
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `[{"tag_name":"v1.0.0"}]`)
+	}))
+	defer srv.Close()
+
+	t.Run("fail", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		var cli = githubClient{baseURL: srv.URL, opts: GithubOptions{OnRateLimit: RateLimitFail}}
+		_, err := cli.Releases("foo/bar")
+		require.Equal(t, ErrRateLimited, errors.Cause(err))
+		require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		var cli = githubClient{baseURL: srv.URL, opts: GithubOptions{
+			OnRateLimit: RateLimitWait,
+			MaxWait:     50 * time.Millisecond,
+		}}
+		var start = time.Now()
+		var waits = testutil.ToFloat64(rateLimitWaits)
+		releases, err := cli.Releases("foo/bar")
+		require.NoError(t, err)
+		require.Len(t, releases, 1)
+		require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		require.True(t, time.Since(start) >= 50*time.Millisecond)
+		require.Equal(t, waits+1, testutil.ToFloat64(rateLimitWaits))
+	})
+}
+
+func TestGithubRateLimitRetryAfter(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	var cli = githubClient{baseURL: srv.URL, opts: GithubOptions{
+		OnRateLimit: RateLimitWait,
+		MaxWait:     time.Second,
+	}}
+	_, err := cli.Releases("foo/bar")
+	require.Equal(t, ErrRateLimited, errors.Cause(err))
+}
+
+func TestGithubRepoMoved(t *testing.T) {
+	var mux = http.NewServeMux()
+	mux.Handle("/repos/old/name/releases", http.RedirectHandler("/repositories/42/releases", http.StatusMovedPermanently))
+	mux.Handle("/repos/old/name", http.RedirectHandler("/repositories/42", http.StatusMovedPermanently))
+	mux.HandleFunc("/repositories/42/releases", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"tag_name":"v1.0.0"}]`)
+	})
+	mux.HandleFunc("/repositories/42", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"full_name":"new/name"}`)
+	})
+	var srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	var cli = githubClient{baseURL: srv.URL}
+	releases, err := cli.Releases("old/name")
+	require.NoError(t, err)
+	require.Equal(t, []Release{{TagName: "v1.0.0"}}, releases)
+	require.Equal(t, float64(1), testutil.ToFloat64(repoMoved.WithLabelValues("old/name", "new/name")))
+
+	ResetMoved()
+	require.Zero(t, testutil.CollectAndCount(repoMoved))
+}
+
+func TestGithubChangelog(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/foo/bar/contents/CHANGELOG.md", r.URL.Path)
+		assert.Equal(t, "application/vnd.github.v3.raw", r.Header.Get("Accept"))
+		fmt.Fprint(w, "# Changelog\n\n## [1.0.0] - 2020-01-01\n")
+	}))
+	defer srv.Close()
+
+	var cli = githubClient{baseURL: srv.URL}
+	changelog, err := cli.Changelog("foo/bar")
+	require.NoError(t, err)
+	require.Equal(t, "# Changelog\n\n## [1.0.0] - 2020-01-01\n", changelog)
+}
+
+func TestGithubRateLimitUsedRatio(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		if r.URL.Path == "/graphql" {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			fmt.Fprint(w, `{"data":{}}`)
+			return
+		}
+		w.Header().Set("X-RateLimit-Remaining", "1250")
+		fmt.Fprint(w, `[]`)
+	}))
+	defer srv.Close()
+
+	var cli = githubClient{baseURL: srv.URL}
+	_, err := cli.Releases("foo/bar")
+	require.NoError(t, err)
+	require.Equal(t, 0.75, testutil.ToFloat64(rateLimitUsed))
+
+	graphqlClient{cli}.ReleasesBatch([]string{"foo/bar"})
+	require.Equal(t, 0.75, testutil.ToFloat64(rateLimitUsed), "graphql has a rate limit of its own")
+}
+
+func TestRateLimitUsedRatioWithoutHeaders(t *testing.T) {
+	_, ok := rateLimitUsedRatio(&http.Response{Header: http.Header{}})
+	require.False(t, ok)
+}
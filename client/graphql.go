@@ -0,0 +1,179 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/log"
+)
+
+// graphqlBatchSize is how many repositories are queried at once.
+const graphqlBatchSize = 20
+
+// nolint: gochecknoglobals
+var (
+	graphqlRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "version_github_graphql_requests_total",
+		Help: "Total number of GitHub GraphQL API requests",
+	})
+	restFallbacks = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "version_github_rest_fallback_total",
+		Help: "Total number of repositories the GraphQL API failed for, which fell back to the REST API",
+	})
+)
+
+// Batcher is implemented by clients that can get the releases of many
+// repositories at once
+type Batcher interface {
+	// ReleasesBatch returns the releases of the given repositories, by
+	// repository. The ones that could not be fetched are missing, and the
+	// caller should fall back to Releases for them.
+	ReleasesBatch(repos []string) map[string][]Release
+}
+
+// Prefetcher is implemented by clients that can fetch ahead of time the
+// releases of the repositories about to be requested
+type Prefetcher interface {
+	Prefetch(repos []string)
+}
+
+// graphqlClient is a github client that also gets the releases of many
+// repositories at once with the GraphQL API. Everything else, including
+// the repositories the GraphQL API failed for, goes through the REST API.
+type graphqlClient struct {
+	githubClient
+}
+
+func (c graphqlClient) ReleasesBatch(repos []string) map[string][]Release {
+	var result = map[string][]Release{}
+	for start := 0; start < len(repos); start += graphqlBatchSize {
+		var end = start + graphqlBatchSize
+		if end > len(repos) {
+			end = len(repos)
+		}
+		found, err := c.query(repos[start:end])
+		restFallbacks.Add(float64(end - start - len(found)))
+		if err != nil {
+			log.Warnf("graphql query failed, falling back to rest: %s", err)
+			continue
+		}
+		for repo, releases := range found {
+			result[repo] = releases
+		}
+	}
+	return result
+}
+
+type graphqlReleases struct {
+	NameWithOwner string `json:"nameWithOwner"`
+	Releases      struct {
+		Nodes []struct {
+			TagName       string    `json:"tagName"`
+			Name          string    `json:"name"`
+			IsDraft       bool      `json:"isDraft"`
+			IsPrerelease  bool      `json:"isPrerelease"`
+			PublishedAt   time.Time `json:"publishedAt"`
+			Description   string    `json:"description"`
+			ReleaseAssets struct {
+				Nodes []struct {
+					Name          string `json:"name"`
+					DownloadCount int    `json:"downloadCount"`
+				} `json:"nodes"`
+			} `json:"releaseAssets"`
+		} `json:"nodes"`
+	} `json:"releases"`
+}
+
+// query gets the releases of the given repositories in a single GraphQL
+// query, newest first like the REST API.
+func (c graphqlClient) query(repos []string) (map[string][]Release, error) {
+	var query strings.Builder
+	var aliases = map[string]string{}
+	query.WriteString("query {")
+	for i, repo := range repos {
+		var parts = strings.SplitN(repo, "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		owner, _ := json.Marshal(parts[0])
+		name, _ := json.Marshal(parts[1])
+		var alias = fmt.Sprintf("r%d", i)
+		aliases[alias] = repo
+		fmt.Fprintf(&query, ` %s: repository(owner: %s, name: %s) { nameWithOwner`+
+			` releases(first: 30, orderBy: {field: CREATED_AT, direction: DESC}) {`+
+			` nodes { tagName name isDraft isPrerelease publishedAt description`+
+			` releaseAssets(first: 100) { nodes { name downloadCount } } } } }`, alias, owner, name)
+	}
+	query.WriteString(" }")
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+
+	body, _ := json.Marshal(map[string]string{"query": query.String()})
+	var url = c.baseURL + "/graphql"
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	graphqlRequests.Inc()
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		debugBody(url, resp.Body, c.opts.DebugBodySize)
+		return nil, errors.Errorf("github responded a non-200 status code: %d", resp.StatusCode)
+	}
+	var result struct {
+		Data   map[string]*graphqlReleases `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the response body")
+	}
+	for _, e := range result.Errors {
+		// the repositories that failed have no data, and will be fetched
+		// with the rest api
+		log.Debugf("graphql error: %s", e.Message)
+	}
+	if result.Data == nil {
+		return nil, errors.New("github responded no data")
+	}
+	var found = map[string][]Release{}
+	for alias, data := range result.Data {
+		var repo, ok = aliases[alias]
+		if !ok || data == nil {
+			continue
+		}
+		// repository names are case insensitive
+		if data.NameWithOwner != "" && !strings.EqualFold(data.NameWithOwner, repo) {
+			movedTo(repo, data.NameWithOwner)
+		}
+		var releases = make([]Release, 0, len(data.Releases.Nodes))
+		for _, node := range data.Releases.Nodes {
+			var assets = make([]Asset, 0, len(node.ReleaseAssets.Nodes))
+			for _, asset := range node.ReleaseAssets.Nodes {
+				assets = append(assets, Asset{Name: asset.Name, DownloadCount: asset.DownloadCount})
+			}
+			releases = append(releases, Release{
+				TagName:     node.TagName,
+				Name:        node.Name,
+				Draft:       node.IsDraft,
+				Prerelease:  node.IsPrerelease,
+				PublishedAt: node.PublishedAt,
+				Body:        node.Description,
+				Assets:      assets,
+			})
+		}
+		found[repo] = releases
+	}
+	return found, nil
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphqlReleasesBatch(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/graphql", r.URL.Path)
+		assert.Equal(t, "token secret", r.Header.Get("Authorization"))
+		var body struct {
+			Query string `json:"query"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.True(t, strings.Contains(body.Query, `r0: repository(owner: "foo", name: "bar")`))
+		assert.True(t, strings.Contains(body.Query, `r1: repository(owner: "foo", name: "gone")`))
+		fmt.Fprint(w, `{"data":{"r0":{"releases":{"nodes":[`+
+			`{"tagName":"v1.1.0","name":"second","isPrerelease":true,"publishedAt":null,"description":"notes","releaseAssets":{"nodes":[{"name":"bar.tar.gz","downloadCount":42}]}},`+
+			`{"tagName":"v1.0.0","isDraft":false,"releaseAssets":{"nodes":[]}}]}},"r1":null},`+
+			`"errors":[{"message":"Could not resolve to a Repository with the name 'foo/gone'."}]}`)
+	}))
+	defer srv.Close()
+
+	var requests, fallbacks = testutil.ToFloat64(graphqlRequests), testutil.ToFloat64(restFallbacks)
+	var cli = graphqlClient{githubClient{baseURL: srv.URL, opts: GithubOptions{Token: "secret"}}}
+	defer func() {
+		require.Equal(t, requests+1, testutil.ToFloat64(graphqlRequests))
+		require.Equal(t, fallbacks+2, testutil.ToFloat64(restFallbacks))
+	}()
+	require.Equal(t, map[string][]Release{
+		"foo/bar": {
+			{TagName: "v1.1.0", Name: "second", Prerelease: true, Body: "notes", Assets: []Asset{{Name: "bar.tar.gz", DownloadCount: 42}}},
+			{TagName: "v1.0.0", Assets: []Asset{}},
+		},
+	}, cli.ReleasesBatch([]string{"foo/bar", "foo/gone", "invalid"}))
+}
+
+func TestGraphqlReleasesBatchFailure(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	var fallbacks = testutil.ToFloat64(restFallbacks)
+	var cli = graphqlClient{githubClient{baseURL: srv.URL}}
+	require.Empty(t, cli.ReleasesBatch([]string{"foo/bar"}))
+	require.Equal(t, fallbacks+1, testutil.ToFloat64(restFallbacks))
+}
+
+func TestNewClientGraphql(t *testing.T) {
+	_, ok := NewClient(GithubOptions{}).(Batcher)
+	require.False(t, ok)
+	_, ok = NewClient(GithubOptions{GraphQL: true}).(Batcher)
+	require.False(t, ok)
+	_, ok = NewClient(GithubOptions{GraphQL: true, Token: "secret"}).(Batcher)
+	require.True(t, ok)
+}
+
+func TestGraphqlReleasesBatchMoved(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"r0":{"nameWithOwner":"bar/foo","releases":{"nodes":[{"tagName":"v1.0.0"}]}},`+
+			`"r1":{"nameWithOwner":"Foo/Same","releases":{"nodes":[]}}}}`)
+	}))
+	defer srv.Close()
+
+	var cli = graphqlClient{githubClient{baseURL: srv.URL, opts: GithubOptions{Token: "secret"}}}
+	require.Len(t, cli.ReleasesBatch([]string{"foo/bar", "foo/same"}), 2)
+	require.Equal(t, 1.0, testutil.ToFloat64(repoMoved.WithLabelValues("foo/bar", "bar/foo")))
+	require.Equal(t, 0.0, testutil.ToFloat64(repoMoved.WithLabelValues("foo/same", "Foo/Same")))
+}
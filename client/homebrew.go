@@ -0,0 +1,66 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// NewHomebrewClient returns a new client that reads the stable version of
+// Homebrew formulae. The repository is the formula name.
+func NewHomebrewClient(opts HTTPOptions) Client {
+	return homebrewClient{
+		baseURL:       "https://formulae.brew.sh",
+		client:        newHTTPClient(opts.Timeout, opts.MaxRedirects, opts.Limiter),
+		debugBodySize: opts.DebugBodySize,
+	}
+}
+
+type homebrewClient struct {
+	baseURL       string
+	client        *http.Client
+	debugBodySize int
+}
+
+func (c homebrewClient) Releases(formula string) ([]Release, error) {
+	// tap formulae have slashes in their names, e.g. user/repo/formula
+	var url = fmt.Sprintf("%s/api/formula/%s.json", c.baseURL, url.PathEscape(formula))
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get formula")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		debugBody(url, resp.Body, c.debugBodySize)
+		return nil, errors.Errorf("homebrew responded a non-200 status code: %d", resp.StatusCode)
+	}
+	body, debug := teeBody(resp.Body, c.debugBodySize)
+	var info struct {
+		Versions struct {
+			Stable string `json:"stable"`
+		} `json:"versions"`
+	}
+	if err := json.NewDecoder(body).Decode(&info); err != nil {
+		debug(url)
+		return nil, errors.Wrap(err, "failed to parse the response body")
+	}
+	if info.Versions.Stable == "" {
+		return nil, errors.Errorf("formula %s has no stable version", formula)
+	}
+	return []Release{{TagName: info.Versions.Stable}}, nil
+}
+
+func (c homebrewClient) Tags(formula string) ([]Tag, error) {
+	releases, err := c.Releases(formula)
+	if err != nil {
+		return nil, err
+	}
+	return []Tag{{Name: releases[0].TagName}}, nil
+}
+
+func (c homebrewClient) Changelog(formula string) (string, error) {
+	return "", errors.New("the homebrew provider has no changelog")
+}
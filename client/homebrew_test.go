@@ -0,0 +1,46 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHomebrewClient(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/formula/wget.json", r.URL.Path)
+		fmt.Fprint(w, `{"name":"wget","versions":{"stable":"1.21.4","head":"HEAD","bottle":true}}`)
+	}))
+	defer srv.Close()
+
+	var cli = homebrewClient{baseURL: srv.URL, client: http.DefaultClient}
+	releases, err := cli.Releases("wget")
+	require.NoError(t, err)
+	require.Equal(t, []Release{{TagName: "1.21.4"}}, releases)
+}
+
+func TestHomebrewClientEscapesFormula(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/formula/user%2Frepo%2Fpython@3.9.json", r.URL.EscapedPath())
+		fmt.Fprint(w, `{"versions":{"stable":"3.9.18"}}`)
+	}))
+	defer srv.Close()
+
+	var cli = homebrewClient{baseURL: srv.URL, client: http.DefaultClient}
+	releases, err := cli.Releases("user/repo/python@3.9")
+	require.NoError(t, err)
+	require.Equal(t, []Release{{TagName: "3.9.18"}}, releases)
+}
+
+func TestHomebrewClientNotFound(t *testing.T) {
+	var srv = httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	var cli = homebrewClient{baseURL: srv.URL, client: http.DefaultClient}
+	_, err := cli.Tags("nope")
+	require.EqualError(t, err, "homebrew responded a non-200 status code: 404")
+}
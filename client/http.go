@@ -0,0 +1,82 @@
+package client
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// HTTPOptions configures the http clients of the providers
+type HTTPOptions struct {
+	Timeout time.Duration
+
+	// DebugBodySize is how much of the response body to log on errors, at
+	// debug level
+	DebugBodySize int
+
+	// MaxRedirects caps how many redirects are followed, 0 means none
+	MaxRedirects int
+
+	// Limiter caps the concurrent requests to each host, nil means no limit
+	Limiter *HostLimiter
+}
+
+// newHTTPClient returns a http client with the given timeout, following up to
+// maxRedirects redirects, and sending requests through the limiter.
+func newHTTPClient(timeout time.Duration, maxRedirects int, limiter *HostLimiter) *http.Client {
+	return &http.Client{
+		Timeout:       timeout,
+		CheckRedirect: checkRedirect(maxRedirects),
+		Transport:     limiter.transport(http.DefaultTransport),
+	}
+}
+
+// checkRedirect logs the followed redirects, and stops following them after
+// max of them, returning the last redirect response instead.
+func checkRedirect(max int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		var log = log.With("from", via[len(via)-1].URL.String()).With("to", req.URL.String())
+		if len(via) > max {
+			log.Warnf("not following more than %d redirects", max)
+			return http.ErrUseLastResponse
+		}
+		log.Debug("following redirect")
+		return nil
+	}
+}
+
+// get requests the url with the given extra headers, which are only sent to
+// the host of the url, not to the ones it redirects to.
+func get(client *http.Client, url string, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if len(headers) > 0 {
+		var copied = *client
+		copied.CheckRedirect = stripHeaders(headers, client.CheckRedirect)
+		client = &copied
+	}
+	return client.Do(req)
+}
+
+// stripHeaders removes the given headers from redirects to another host, as
+// net/http only does so for the Authorization and Cookie ones, before calling
+// next, if any.
+func stripHeaders(headers map[string]string, next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if req.URL.Host != via[0].URL.Host {
+			for name := range headers {
+				req.Header.Del(name)
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		return next(req, via)
+	}
+}
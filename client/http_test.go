@@ -0,0 +1,77 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxRedirects(t *testing.T) {
+	// /N redirects to /N-1 until /0, which responds OK
+	var requests int32
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		n, err := strconv.Atoi(r.URL.Path[1:])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if n == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.Redirect(w, r, "/"+strconv.Itoa(n-1), http.StatusFound)
+	}))
+	defer srv.Close()
+
+	// /2 takes 2 redirects to reach /0
+	for max, tt := range map[int]struct {
+		status   int
+		requests int32
+	}{
+		0: {http.StatusFound, 1},
+		1: {http.StatusFound, 2},
+		2: {http.StatusOK, 3},
+		5: {http.StatusOK, 3},
+	} {
+		max, tt := max, tt
+		t.Run(strconv.Itoa(max), func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+			resp, err := get(newHTTPClient(time.Second, max, nil), srv.URL+"/2", nil)
+			require.NoError(t, err)
+			defer resp.Body.Close() // nolint: errcheck
+			require.Equal(t, tt.status, resp.StatusCode)
+			require.Equal(t, tt.requests, atomic.LoadInt32(&requests))
+		})
+	}
+}
+
+func TestRedirectHeaders(t *testing.T) {
+	var received = make(chan string, 3)
+	var other = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- "other " + r.Header.Get("X-Api-Key")
+	}))
+	defer other.Close()
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.URL.Path + " " + r.Header.Get("X-Api-Key")
+		switch r.URL.Path {
+		case "/":
+			http.Redirect(w, r, "/same", http.StatusFound)
+		case "/same":
+			http.Redirect(w, r, other.URL, http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := get(newHTTPClient(time.Second, 5, nil), srv.URL, map[string]string{"X-Api-Key": "secret"})
+	require.NoError(t, err)
+	defer resp.Body.Close() // nolint: errcheck
+	require.Equal(t, "/ secret", <-received)
+	require.Equal(t, "/same secret", <-received)
+	require.Equal(t, "other ", <-received)
+}
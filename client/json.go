@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// JSONFields tells where the versions are in the response of a JSON API
+type JSONFields struct {
+	// VersionsPath is the dot separated path of object keys to the array of
+	// versions, empty if the response itself is the array
+	VersionsPath string
+
+	// VersionField is the field of each item holding its version, empty if
+	// the items are the versions themselves
+	VersionField string
+
+	// PrereleaseField is the optional boolean field of each item telling
+	// whether it is a prerelease
+	PrereleaseField string
+}
+
+// NewJSONClient returns a new client that reads the versions listed in the
+// response of a JSON API. The repository is the url of the API, which is
+// requested with the given extra headers.
+func NewJSONClient(fields JSONFields, headers map[string]string, opts HTTPOptions) Client {
+	return jsonClient{
+		fields:        fields,
+		client:        newHTTPClient(opts.Timeout, opts.MaxRedirects, opts.Limiter),
+		headers:       headers,
+		debugBodySize: opts.DebugBodySize,
+	}
+}
+
+type jsonClient struct {
+	fields        JSONFields
+	client        *http.Client
+	headers       map[string]string
+	debugBodySize int
+}
+
+func (c jsonClient) Releases(url string) ([]Release, error) {
+	resp, err := get(c.client, url, c.headers)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get versions")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		debugBody(url, resp.Body, c.debugBodySize)
+		return nil, errors.Errorf("json api responded a non-200 status code: %d", resp.StatusCode)
+	}
+	bts, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the response body")
+	}
+	releases, err := c.fields.releases(bts)
+	if err != nil {
+		debugBody(url, bytes.NewReader(bts), c.debugBodySize)
+		return nil, err
+	}
+	return releases, nil
+}
+
+func (c jsonClient) Tags(url string) ([]Tag, error) {
+	releases, err := c.Releases(url)
+	if err != nil {
+		return nil, err
+	}
+	var tags = make([]Tag, 0, len(releases))
+	for _, release := range releases {
+		tags = append(tags, Tag{Name: release.TagName})
+	}
+	return tags, nil
+}
+
+func (c jsonClient) Changelog(url string) (string, error) {
+	return "", errors.New("the json provider has no changelog")
+}
+
+// releases extracts the releases from the given response body.
+func (f JSONFields) releases(bts []byte) ([]Release, error) {
+	var raw = json.RawMessage(bts)
+	if f.VersionsPath != "" {
+		for _, key := range strings.Split(f.VersionsPath, ".") {
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &object); err != nil {
+				return nil, errors.Wrapf(err, "failed to find %s in the response body", f.VersionsPath)
+			}
+			var ok bool
+			if raw, ok = object[key]; !ok {
+				return nil, errors.Errorf("failed to find %s in the response body", f.VersionsPath)
+			}
+		}
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, errors.Wrap(err, "versions are not an array")
+	}
+	var releases = make([]Release, 0, len(items))
+	for _, item := range items {
+		if f.VersionField == "" {
+			var version string
+			if err := json.Unmarshal(item, &version); err != nil {
+				return nil, errors.Wrap(err, "failed to parse version")
+			}
+			releases = append(releases, Release{TagName: version})
+			continue
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(item, &object); err != nil {
+			return nil, errors.Wrap(err, "failed to parse version")
+		}
+		var release Release
+		if err := json.Unmarshal(object[f.VersionField], &release.TagName); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", f.VersionField)
+		}
+		if value, ok := object[f.PrereleaseField]; ok && f.PrereleaseField != "" {
+			if err := json.Unmarshal(value, &release.Prerelease); err != nil {
+				return nil, errors.Wrapf(err, "failed to parse %s", f.PrereleaseField)
+			}
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
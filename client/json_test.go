@@ -0,0 +1,55 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONClient(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"releases":[{"tag_name":"v1.1.0-rc.1","upcoming":true},{"tag_name":"v1.0.0","upcoming":false}]}}`)
+	}))
+	defer srv.Close()
+
+	var cli = NewJSONClient(JSONFields{
+		VersionsPath:    "data.releases",
+		VersionField:    "tag_name",
+		PrereleaseField: "upcoming",
+	}, nil, HTTPOptions{Timeout: time.Second})
+	releases, err := cli.Releases(srv.URL)
+	require.NoError(t, err)
+	require.Equal(t, []Release{{TagName: "v1.1.0-rc.1", Prerelease: true}, {TagName: "v1.0.0"}}, releases)
+
+	tags, err := cli.Tags(srv.URL)
+	require.NoError(t, err)
+	require.Equal(t, []Tag{{Name: "v1.1.0-rc.1"}, {Name: "v1.0.0"}}, tags)
+}
+
+func TestJSONFields(t *testing.T) {
+	t.Run("plain array", func(t *testing.T) {
+		releases, err := JSONFields{}.releases([]byte(`["1.0.0","1.1.0"]`))
+		require.NoError(t, err)
+		require.Equal(t, []Release{{TagName: "1.0.0"}, {TagName: "1.1.0"}}, releases)
+	})
+	t.Run("missing path", func(t *testing.T) {
+		_, err := JSONFields{VersionsPath: "data.versions"}.releases([]byte(`{"data":{}}`))
+		require.EqualError(t, err, "failed to find data.versions in the response body")
+	})
+	t.Run("not an array", func(t *testing.T) {
+		_, err := JSONFields{VersionsPath: "data"}.releases([]byte(`{"data":{}}`))
+		require.Error(t, err)
+	})
+}
+
+func TestJSONClientNon200(t *testing.T) {
+	var srv = httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := NewJSONClient(JSONFields{}, nil, HTTPOptions{Timeout: time.Second}).Releases(srv.URL)
+	require.EqualError(t, err, "json api responded a non-200 status code: 404")
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// HostLimiter caps the number of concurrent requests made to each host. A
+// single one is meant to be shared by all the clients, so the cap holds
+// across providers.
+type HostLimiter struct {
+	max   int
+	mutex sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+// NewHostLimiter returns a new limiter allowing max concurrent requests to
+// each host, 0 means no limit.
+func NewHostLimiter(max int) *HostLimiter {
+	return &HostLimiter{
+		max:   max,
+		hosts: map[string]chan struct{}{},
+	}
+}
+
+// transport returns a transport sending the requests with next once there
+// is a free slot for their host.
+func (l *HostLimiter) transport(next http.RoundTripper) http.RoundTripper {
+	if l == nil || l.max <= 0 {
+		return next
+	}
+	return limitedTransport{limiter: l, next: next}
+}
+
+func (l *HostLimiter) semaphore(host string) chan struct{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	sem, ok := l.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, l.max)
+		l.hosts[host] = sem
+	}
+	return sem
+}
+
+type limitedTransport struct {
+	limiter *HostLimiter
+	next    http.RoundTripper
+}
+
+// RoundTrip waits for a free slot, giving up when the request is canceled.
+// The slot is only released when the response body is closed, so reading it
+// counts as part of the request.
+func (t limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var sem = t.limiter.semaphore(req.URL.Host)
+	select {
+	case sem <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		<-sem
+		return resp, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { <-sem }}
+	return resp, nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
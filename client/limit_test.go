@@ -0,0 +1,63 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostLimiter(t *testing.T) {
+	var current, peak int32
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n = atomic.AddInt32(&current, 1)
+		defer atomic.AddInt32(&current, -1)
+		for {
+			var p = atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	var cli = newHTTPClient(time.Second, 0, NewHostLimiter(2))
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := get(cli, srv.URL, nil)
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(2), atomic.LoadInt32(&peak))
+}
+
+func TestHostLimiterDisabled(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	require.Equal(t, http.DefaultTransport, NewHostLimiter(0).transport(http.DefaultTransport))
+	var nilLimiter *HostLimiter
+	require.Equal(t, http.DefaultTransport, nilLimiter.transport(http.DefaultTransport))
+}
+
+func TestHostLimiterCanceled(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	var limiter = NewHostLimiter(1)
+	limiter.semaphore(srv.Listener.Addr().String()) <- struct{}{}
+	_, err := get(newHTTPClient(10*time.Millisecond, 0, limiter), srv.URL, nil)
+	require.Error(t, err)
+}
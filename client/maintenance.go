@@ -0,0 +1,187 @@
+package client
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/log"
+)
+
+// ErrMaintenance is returned in maintenance mode when there is no last known
+// result to serve
+var ErrMaintenance = errors.New("maintenance mode: no last known result")
+
+// nolint: gochecknoglobals
+var maintenanceMode = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "version_maintenance_mode",
+	Help: "Whether the exporter is in maintenance mode, serving last known results without calling upstream",
+})
+
+// Maintenance holds whether maintenance mode is enabled
+type Maintenance struct {
+	enabled int32
+}
+
+// Set enables or disables maintenance mode
+func (m *Maintenance) Set(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&m.enabled, value)
+	maintenanceMode.Set(float64(value))
+}
+
+// Enabled reports whether maintenance mode is enabled
+func (m *Maintenance) Enabled() bool {
+	return atomic.LoadInt32(&m.enabled) == 1
+}
+
+// NewMaintenanceClient returns a client that remembers the last successful
+// results of the underlying client, and serves them without calling it
+// while in maintenance mode.
+func NewMaintenanceClient(client Client, maintenance *Maintenance) Client {
+	var c = &maintenanceClient{
+		client:    client,
+		lastKnown: newLastKnown(maintenance),
+	}
+	if batcher, ok := client.(Batcher); ok {
+		return maintenanceBatcher{maintenanceClient: c, batcher: batcher}
+	}
+	return c
+}
+
+// NewMaintenancePins returns pins that remember the last successful results
+// of the underlying pins, and serve them without calling it while in
+// maintenance mode.
+func NewMaintenancePins(pins Pins, maintenance *Maintenance) Pins {
+	return &maintenancePins{
+		pins:      pins,
+		lastKnown: newLastKnown(maintenance),
+	}
+}
+
+type maintenanceClient struct {
+	client Client
+	*lastKnown
+}
+
+// maintenanceBatcher is a maintenance client whose underlying client gets the
+// releases of many repositories at once.
+type maintenanceBatcher struct {
+	*maintenanceClient
+	batcher Batcher
+}
+
+type maintenancePins struct {
+	pins Pins
+	*lastKnown
+}
+
+func (p *maintenancePins) Pinned(url, tool string) (string, error) {
+	var version string
+	result, err := p.last("pin:"+url+"#"+tool, func() (interface{}, error) {
+		return p.pins.Pinned(url, tool)
+	})
+	if result != nil {
+		version = result.(string)
+	}
+	return version, err
+}
+
+// lastKnown holds the last successful results by key.
+type lastKnown struct {
+	maintenance *Maintenance
+	mutex       sync.Mutex
+	known       map[string]interface{}
+}
+
+func newLastKnown(maintenance *Maintenance) *lastKnown {
+	return &lastKnown{
+		maintenance: maintenance,
+		known:       map[string]interface{}{},
+	}
+}
+
+func (c *maintenanceClient) Releases(repo string) ([]Release, error) {
+	var releases []Release
+	result, err := c.last("releases:"+repo, func() (interface{}, error) {
+		return c.client.Releases(repo)
+	})
+	if result != nil {
+		releases = result.([]Release)
+	}
+	return releases, err
+}
+
+func (c *maintenanceClient) Tags(repo string) ([]Tag, error) {
+	var tags []Tag
+	result, err := c.last("tags:"+repo, func() (interface{}, error) {
+		return c.client.Tags(repo)
+	})
+	if result != nil {
+		tags = result.([]Tag)
+	}
+	return tags, err
+}
+
+func (c *maintenanceClient) Changelog(repo string) (string, error) {
+	var changelog string
+	result, err := c.last("changelog:"+repo, func() (interface{}, error) {
+		return c.client.Changelog(repo)
+	})
+	if result != nil {
+		changelog = result.(string)
+	}
+	return changelog, err
+}
+
+// ReleasesBatch gets the releases of many repositories at once, remembering
+// them as well.
+func (c maintenanceBatcher) ReleasesBatch(repos []string) map[string][]Release {
+	var result = map[string][]Release{}
+	if c.maintenance.Enabled() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		for _, repo := range repos {
+			if known, ok := c.known["releases:"+repo]; ok {
+				result[repo] = known.([]Release)
+			}
+		}
+		return result
+	}
+	var batch = c.batcher.ReleasesBatch(repos)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for repo, releases := range batch {
+		c.known["releases:"+repo] = releases
+		result[repo] = releases
+	}
+	return result
+}
+
+// last serves the last known result of key in maintenance mode, or fetches
+// and remembers it otherwise.
+func (l *lastKnown) last(key string, fetch func() (interface{}, error)) (interface{}, error) {
+	if l.maintenance.Enabled() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		result, ok := l.known[key]
+		if !ok {
+			return nil, ErrMaintenance
+		}
+		log.Debugf("maintenance mode: using last known result for %s", key)
+		return result, nil
+	}
+	result, err := fetch()
+	if err != nil {
+		return result, err
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.known[key] = result
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceClient(t *testing.T) {
+	var rel = []Release{{TagName: "v1.0.0"}}
+	var maintenance Maintenance
+	var cli = NewMaintenanceClient(cacheTestClient{result: &rel}, &maintenance)
+
+	res, err := cli.Releases("foo")
+	require.NoError(t, err)
+	require.Equal(t, rel, res)
+
+	maintenance.Set(true)
+	require.Equal(t, 1.0, testutil.ToFloat64(maintenanceMode))
+	rel = append(rel, Release{TagName: "v1.1.0"})
+
+	t.Run("last known result", func(t *testing.T) {
+		res, err := cli.Releases("foo")
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+	})
+
+	t.Run("no last known result", func(t *testing.T) {
+		_, err := cli.Tags("foo")
+		require.Equal(t, ErrMaintenance, err)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		maintenance.Set(false)
+		require.Equal(t, 0.0, testutil.ToFloat64(maintenanceMode))
+		res, err := cli.Releases("foo")
+		require.NoError(t, err)
+		require.Len(t, res, 2)
+	})
+}
+
+func TestMaintenanceClientReleasesBatch(t *testing.T) {
+	var maintenance Maintenance
+	var upstream = &batchTestClient{}
+	var cli = NewMaintenanceClient(upstream, &maintenance).(Batcher)
+
+	require.Len(t, cli.ReleasesBatch([]string{"foo/bar"}), 1)
+	maintenance.Set(true)
+	defer maintenance.Set(false)
+	require.Len(t, cli.ReleasesBatch([]string{"foo/bar", "foo/baz"}), 1)
+	require.Len(t, upstream.batches, 1)
+
+	_, ok := NewMaintenanceClient(cacheTestClient{}, &maintenance).(Batcher)
+	require.False(t, ok, "should only batch when the underlying client does")
+}
+
+func TestCachedMaintenanceClient(t *testing.T) {
+	var maintenance Maintenance
+	var upstream = &batchTestClient{}
+	var cli = NewCachedClient(NewMaintenanceClient(upstream, &maintenance), cache.New(time.Minute, time.Minute), "")
+
+	maintenance.Set(true)
+	_, err := cli.Releases("foo/bar")
+	require.Equal(t, ErrMaintenance, err)
+	require.Zero(t, upstream.calls)
+
+	maintenance.Set(false)
+	_, err = cli.Releases("foo/bar")
+	require.NoError(t, err)
+	require.Equal(t, 1, upstream.calls, "should not have cached the maintenance error")
+}
+
+func TestMaintenancePins(t *testing.T) {
+	var maintenance Maintenance
+	var upstream = fakePins{"https://example.com/.tool-versions#golang": "1.15.2"}
+	var pins = NewMaintenancePins(upstream, &maintenance)
+
+	version, err := pins.Pinned("https://example.com/.tool-versions", "golang")
+	require.NoError(t, err)
+	require.Equal(t, "1.15.2", version)
+
+	maintenance.Set(true)
+	defer maintenance.Set(false)
+	upstream["https://example.com/.tool-versions#golang"] = "1.16.0"
+	version, err = pins.Pinned("https://example.com/.tool-versions", "golang")
+	require.NoError(t, err)
+	require.Equal(t, "1.15.2", version, "should serve the last known pin")
+
+	_, err = pins.Pinned("https://example.com/.tool-versions", "nodejs")
+	require.Equal(t, ErrMaintenance, err)
+}
+
+type fakePins map[string]string
+
+func (p fakePins) Pinned(url, tool string) (string, error) {
+	version, ok := p[url+"#"+tool]
+	if !ok {
+		return "", errors.New("not pinned")
+	}
+	return version, nil
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+)
+
+// Pins reads versions pinned in remote files
+type Pins interface {
+	// Pinned returns the version of tool pinned in the .tool-versions file
+	// (asdf format) at url
+	Pinned(url, tool string) (string, error)
+}
+
+// NewPins returns new pins read over http
+func NewPins(opts HTTPOptions) Pins {
+	return httpPins{
+		client:        newHTTPClient(opts.Timeout, opts.MaxRedirects, opts.Limiter),
+		debugBodySize: opts.DebugBodySize,
+	}
+}
+
+type httpPins struct {
+	client        *http.Client
+	debugBodySize int
+}
+
+func (p httpPins) Pinned(url, tool string) (string, error) {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get pinned versions")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		debugBody(url, resp.Body, p.debugBodySize)
+		return "", errors.Errorf("pinned versions file responded a non-200 status code: %d", resp.StatusCode)
+	}
+	var scanner = bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var line = scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		var fields = strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == tool {
+			return fields[1], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", errors.Wrap(err, "failed to read pinned versions")
+	}
+	return "", errors.Errorf("%s is not pinned in %s", tool, url)
+}
+
+// NewCachedPins returns pins that cache the results of the given ones
+func NewCachedPins(pins Pins, cache *cache.Cache) Pins {
+	return cachedPins{
+		pins:  pins,
+		cache: cache,
+	}
+}
+
+type cachedPins struct {
+	pins  Pins
+	cache *cache.Cache
+}
+
+func (p cachedPins) Pinned(url, tool string) (string, error) {
+	var key = "pin:" + url + "#" + tool
+	if cached, found := p.cache.Get(key); found {
+		return cached.(string), nil
+	}
+	version, err := p.pins.Pinned(url, tool)
+	if err != nil {
+		return version, err
+	}
+	p.cache.Set(key, version, cache.DefaultExpiration)
+	return version, nil
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPins(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# tools\nnodejs 18.17.1\ngolang 1.21.0 1.20.7 # main one first\n")
+	}))
+	defer srv.Close()
+
+	var pins = NewPins(HTTPOptions{Timeout: time.Second})
+	version, err := pins.Pinned(srv.URL, "golang")
+	require.NoError(t, err)
+	require.Equal(t, "1.21.0", version)
+
+	_, err = pins.Pinned(srv.URL, "terraform")
+	require.EqualError(t, err, fmt.Sprintf("terraform is not pinned in %s", srv.URL))
+}
+
+func TestCachedPins(t *testing.T) {
+	var version = "18.17.1"
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "nodejs %s\n", version)
+	}))
+	defer srv.Close()
+
+	var pins = NewCachedPins(NewPins(HTTPOptions{Timeout: time.Second}), cache.New(time.Minute, time.Minute))
+	pinned, err := pins.Pinned(srv.URL, "nodejs")
+	require.NoError(t, err)
+	require.Equal(t, "18.17.1", pinned)
+
+	version = "20.5.0"
+	pinned, err = pins.Pinned(srv.URL, "nodejs")
+	require.NoError(t, err)
+	require.Equal(t, "18.17.1", pinned)
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// NewServiceClient returns a new client that reads the version a live
+// service reports on a JSON endpoint, like the kubernetes /version one.
+// The repository is the url of the endpoint, which is requested with the
+// given extra headers.
+func NewServiceClient(headers map[string]string, opts HTTPOptions) Client {
+	return serviceClient{
+		client:        newHTTPClient(opts.Timeout, opts.MaxRedirects, opts.Limiter),
+		headers:       headers,
+		debugBodySize: opts.DebugBodySize,
+	}
+}
+
+type serviceClient struct {
+	client        *http.Client
+	headers       map[string]string
+	debugBodySize int
+}
+
+func (c serviceClient) Releases(url string) ([]Release, error) {
+	resp, err := get(c.client, url, c.headers)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get service version")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		debugBody(url, resp.Body, c.debugBodySize)
+		return nil, errors.Errorf("service responded a non-200 status code: %d", resp.StatusCode)
+	}
+	reader, debug := teeBody(resp.Body, c.debugBodySize)
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(reader).Decode(&body); err != nil {
+		debug(url)
+		return nil, errors.Wrap(err, "failed to parse the response body")
+	}
+	var version = serviceVersion(body)
+	if version == "" {
+		return nil, errors.New("service did not report a version")
+	}
+	return []Release{{TagName: version}}, nil
+}
+
+func (c serviceClient) Tags(url string) ([]Tag, error) {
+	releases, err := c.Releases(url)
+	if err != nil {
+		return nil, err
+	}
+	return []Tag{{Name: releases[0].TagName}}, nil
+}
+
+func (c serviceClient) Changelog(url string) (string, error) {
+	return "", errors.New("the service provider has no changelog")
+}
+
+// serviceVersion finds the version in the common /version shapes:
+// {"gitVersion": "v1.18.0"} (kubernetes), {"version": "1.2.3"},
+// {"Version": "19.03.1"} (docker) and {"version": {"number": "7.9.0"}}
+// (elasticsearch).
+func serviceVersion(body map[string]json.RawMessage) string {
+	for _, field := range []string{"gitVersion", "version", "Version"} {
+		raw, ok := body[field]
+		if !ok {
+			continue
+		}
+		var version string
+		if err := json.Unmarshal(raw, &version); err == nil && version != "" {
+			return version
+		}
+		var nested struct {
+			Number string `json:"number"`
+		}
+		if err := json.Unmarshal(raw, &nested); err == nil && nested.Number != "" {
+			return nested.Number
+		}
+	}
+	return ""
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceClient(t *testing.T) {
+	for body, version := range map[string]string{
+		`{"major":"1","minor":"18","gitVersion":"v1.18.3"}`: "v1.18.3",
+		`{"version":"1.2.3"}`:                               "1.2.3",
+		`{"Version":"19.03.1","ApiVersion":"1.40"}`:         "19.03.1",
+		`{"name":"es","version":{"number":"7.9.0"}}`:        "7.9.0",
+	} {
+		body, version := body, version
+		t.Run(version, func(t *testing.T) {
+			var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, body)
+			}))
+			defer srv.Close()
+
+			releases, err := NewServiceClient(nil, HTTPOptions{Timeout: time.Second}).Releases(srv.URL + "/version")
+			require.NoError(t, err)
+			require.Equal(t, []Release{{TagName: version}}, releases)
+		})
+	}
+}
+
+func TestServiceClientNoVersion(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"ok"}`)
+	}))
+	defer srv.Close()
+
+	_, err := NewServiceClient(nil, HTTPOptions{Timeout: time.Second}).Releases(srv.URL)
+	require.EqualError(t, err, "service did not report a version")
+}
+
+func TestServiceClientNon200(t *testing.T) {
+	var srv = httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := NewServiceClient(nil, HTTPOptions{Timeout: time.Second}).Tags(srv.URL)
+	require.EqualError(t, err, "service responded a non-200 status code: 404")
+}
+
+func TestServiceClientHeaders(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"gitVersion":"v1.18.3"}`)
+	}))
+	defer srv.Close()
+
+	var cli = NewServiceClient(map[string]string{"Authorization": "Bearer secret"}, HTTPOptions{Timeout: time.Second})
+	releases, err := cli.Releases(srv.URL)
+	require.NoError(t, err)
+	require.Equal(t, []Release{{TagName: "v1.18.3"}}, releases)
+}
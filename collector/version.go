@@ -1,6 +1,11 @@
 package collector
 
 import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -8,26 +13,132 @@ import (
 	"github.com/caarlos0/version_exporter/client"
 	"github.com/caarlos0/version_exporter/config"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/common/log"
 )
 
+const (
+	sourceReleases  = "releases"
+	sourceTags      = "tags"
+	sourceChangelog = "changelog"
+	sourceAuto      = "auto"
+
+	modeReleaseBranch = "release-branch"
+	modeNewestBuild   = "newest-build"
+	modeLatest        = "latest"
+
+	requireAssets   = "assets"
+	requireBody     = "body"
+	requireNonDraft = "non-draft"
+
+	defaultSecurityMarker = "security"
+
+	labelFieldName = "name"
+	labelFieldTag  = "tag"
+
+	providerGithub   = "github"
+	providerExec     = "exec"
+	providerService  = "service"
+	providerHomebrew = "homebrew"
+	providerJSON     = "json"
+)
+
+// maxConcurrentForks caps how many forks of a repository are fetched at the
+// same time.
+const maxConcurrentForks = 4
+
+// nolint: gochecknoglobals
+var collectsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "version_collects_in_flight",
+	Help: "Number of collects running or waiting for the previous one to finish",
+})
+
+// Options configures optional collector features.
+type Options struct {
+	// Commands holds the exec provider clients by command. Only commands
+	// in this map can be used by the repositories.
+	Commands map[string]client.Client
+
+	// Service returns the client of the service provider for the given
+	// extra headers, which reads the version a live service reports on its
+	// url.
+	Service func(headers map[string]string) client.Client
+
+	// Homebrew is the client of the homebrew provider, which reads the
+	// stable version of formulae.
+	Homebrew client.Client
+
+	// Pins reads the versions pinned in the constraint files.
+	Pins client.Pins
+
+	// JSON returns the client of the json provider for the given fields and
+	// extra headers, which reads the versions listed by a JSON API.
+	JSON func(fields client.JSONFields, headers map[string]string) client.Client
+
+	// LenientSemver extracts versions from malformed tags instead of
+	// ignoring them.
+	LenientSemver bool
+
+	// DurationAlpha is the smoothing factor of the collect duration moving
+	// average, between 0 and 1. Zero disables it.
+	DurationAlpha float64
+
+	// DownloadCount exposes how many times the assets of the latest
+	// releases were downloaded.
+	DownloadCount bool
+}
+
 type versionCollector struct {
-	mutex  sync.Mutex
-	config *config.Config
-	client client.Client
+	mutex   sync.Mutex
+	config  *config.Config
+	client  client.Client
+	options Options
+
+	// upToDateSince holds, by repository, since when it is up to date
+	upToDateSince map[string]time.Time
+
+	// durations holds, by repository, the moving average of how long
+	// collecting it took in seconds
+	durations map[string]float64
+
+	// httpClients holds the service and json provider clients by their
+	// settings, so they keep their caches between collects. It is created
+	// on first use.
+	httpClients map[string]client.Client
 
-	up             *prometheus.Desc
-	upToDate       *prometheus.Desc
-	scrapeDuration *prometheus.Desc
+	up                  *prometheus.Desc
+	upToDate            *prometheus.Desc
+	upToDateStreak      *prometheus.Desc
+	outOfWindow         *prometheus.Desc
+	outOfConstraint     *prometheus.Desc
+	securityAvailable   *prometheus.Desc
+	nextMajorPrerelease *prometheus.Desc
+	linePrerelease      *prometheus.Desc
+	sourceInfo          *prometheus.Desc
+	paramsInfo          *prometheus.Desc
+	forkInfo            *prometheus.Desc
+	downloadCount       *prometheus.Desc
+	releasesFetched     *prometheus.Desc
+	releasesFound       *prometheus.Desc
+	majorVersions       *prometheus.Desc
+	noRecentRelease     *prometheus.Desc
+	releaseOverdue      *prometheus.Desc
+	scrapeDuration      *prometheus.Desc
+	durationEWMA        *prometheus.Desc
+	processingDuration  *prometheus.Desc
+	configInfo          *prometheus.Desc
 }
 
 // NewVersionCollector returns a versions collector
-func NewVersionCollector(config *config.Config, client client.Client) prometheus.Collector {
+func NewVersionCollector(config *config.Config, client client.Client, options Options) prometheus.Collector {
 	const namespace = "version"
 	const subsystem = ""
 	return &versionCollector{
-		config: config,
-		client: client,
+		config:        config,
+		client:        client,
+		options:       options,
+		upToDateSince: map[string]time.Time{},
+		durations:     map[string]float64{},
 		up: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "up"),
 			"Exporter is being able to talk with GitHub API",
@@ -37,7 +148,97 @@ func NewVersionCollector(config *config.Config, client client.Client) prometheus
 		upToDate: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "up_to_date"),
 			"Wether the repository latest version is in the specified semantic versioning range",
-			[]string{"repository", "constraint", "latest"},
+			[]string{"repository", "constraint", "latest", "latest_raw_tag"},
+			nil,
+		),
+		upToDateStreak: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "up_to_date_streak_seconds"),
+			"How long the repository has continuously been up to date in seconds",
+			[]string{"repository"},
+			nil,
+		),
+		outOfWindow: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "out_of_window_available"),
+			"Number of stable releases outside of the min_version and max_version window",
+			[]string{"repository"},
+			nil,
+		),
+		outOfConstraint: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "out_of_constraint_available"),
+			"Number of stable releases newer than the newest one satisfying the constraint",
+			[]string{"repository"},
+			nil,
+		),
+		securityAvailable: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "security_release_available"),
+			"Whether the newest security release does not satisfy the constraint",
+			[]string{"repository"},
+			nil,
+		),
+		nextMajorPrerelease: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "next_major_prerelease_info"),
+			"Newest prerelease of a major version greater than the one of the constraint",
+			[]string{"repository", "version", "tag"},
+			nil,
+		),
+		linePrerelease: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "line_prerelease_info"),
+			"Newest prerelease of the major.minor line of the constraint, newer than the newest release of it",
+			[]string{"repository", "version", "tag"},
+			nil,
+		),
+		sourceInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "source_info"),
+			"Which source answered for repositories using the auto source",
+			[]string{"repository", "source"},
+			nil,
+		),
+		paramsInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "params_info"),
+			"Effective settings of the repository, after defaulting",
+			[]string{"repository", "constraint", "provider", "source", "mode", "lenient_semver"},
+			nil,
+		),
+		forkInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "latest_fork_info"),
+			"Which of the repository and its forks has the latest version",
+			[]string{"repository", "fork"},
+			nil,
+		),
+		downloadCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "latest_release_download_count"),
+			"Number of downloads of the assets of the latest release",
+			[]string{"repository"},
+			nil,
+		),
+		releasesFetched: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "releases_fetched"),
+			"Number of releases fetched for the repository, before any filtering",
+			[]string{"repository"},
+			nil,
+		),
+		releasesFound: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "releases_found"),
+			"Number of parseable stable releases considered for the repository",
+			[]string{"repository"},
+			nil,
+		),
+		majorVersions: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "major_versions_available"),
+			"Number of distinct major versions among the repository stable releases",
+			[]string{"repository"},
+			nil,
+		),
+		releaseOverdue: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "release_overdue"),
+			"Whether the newest stable release was published longer ago than the configured expected_release_interval",
+			[]string{"repository"},
+			nil,
+		),
+		noRecentRelease: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "no_recent_release"),
+			"Whether no stable release was published within the configured published_within duration",
+			[]string{"repository"},
 			nil,
 		),
 		scrapeDuration: prometheus.NewDesc(
@@ -46,6 +247,24 @@ func NewVersionCollector(config *config.Config, client client.Client) prometheus
 			nil,
 			nil,
 		),
+		durationEWMA: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "probe_duration_ewma_seconds"),
+			"Exponential moving average of how long collecting the repository took in seconds",
+			[]string{"repository"},
+			nil,
+		),
+		processingDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "processing_duration_seconds"),
+			"Returns how long parsing, sorting and comparing versions took in seconds, excluding API calls",
+			nil,
+			nil,
+		),
+		configInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "config_info"),
+			"Short hash of the loaded config file",
+			[]string{"hash"},
+			nil,
+		),
 	}
 }
 
@@ -53,35 +272,223 @@ func NewVersionCollector(config *config.Config, client client.Client) prometheus
 func (c *versionCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.up
 	ch <- c.upToDate
+	ch <- c.upToDateStreak
+	ch <- c.outOfWindow
+	ch <- c.outOfConstraint
+	ch <- c.securityAvailable
+	ch <- c.nextMajorPrerelease
+	ch <- c.linePrerelease
+	ch <- c.sourceInfo
+	ch <- c.paramsInfo
+	ch <- c.forkInfo
+	ch <- c.downloadCount
+	ch <- c.releasesFetched
+	ch <- c.releasesFound
+	ch <- c.majorVersions
+	ch <- c.noRecentRelease
+	ch <- c.releaseOverdue
 	ch <- c.scrapeDuration
+	ch <- c.durationEWMA
+	ch <- c.processingDuration
+	ch <- c.configInfo
 }
 
 // Collect all metrics
 func (c *versionCollector) Collect(ch chan<- prometheus.Metric) {
+	collectsInFlight.Inc()
+	defer collectsInFlight.Dec()
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	var success = true
 	var start = time.Now()
-	for repo, constraint := range c.config.Repositories {
+	c.prune()
+	c.prefetch()
+	var processing time.Duration
+	for repo, settings := range c.config.Repositories {
 		var log = log.With("repo", repo)
+		var repoStart = time.Now()
 		log.Debug("collecting")
+		constraint, err := c.constraint(settings)
+		if err != nil {
+			log.Errorf("failed to collect for %s: %s", repo, err.Error())
+			success = false
+			continue
+		}
+		settings.Constraint = constraint
+		ch <- c.params(repo, settings)
 		sconstraint, err := semver.NewConstraint(constraint)
 		if err != nil {
 			log.Errorf("failed to collect for %s: %s", repo, err.Error())
 			success = false
 			continue
 		}
-		version, err := getLatest(c.client, repo)
+		cli, err := c.clientFor(settings)
 		if err != nil {
 			log.Errorf("failed to collect for %s: %s", repo, err.Error())
 			success = false
 			continue
 		}
+		var target = repo
+		if settings.Provider == providerService || settings.Provider == providerJSON {
+			target = settings.URL
+		}
+		var auto = settings.Source == sourceAuto
+		var releases []client.Release
+		if auto {
+			// the filters need to know which source actually answered
+			releases, settings.Source, err = findAutoReleases(cli, target)
+		} else {
+			releases, err = findReleases(cli, target, settings.Source)
+		}
+		if err != nil {
+			log.Errorf("failed to collect for %s: %s", repo, err.Error())
+			success = false
+			continue
+		}
+		var origins map[string]string
+		if len(settings.Forks) > 0 {
+			releases, origins = mergeForks(cli, repo, releases, settings)
+		}
+		if auto {
+			ch <- prometheus.MustNewConstMetric(
+				c.sourceInfo,
+				prometheus.GaugeValue,
+				1,
+				repo,
+				settings.Source,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.releasesFetched,
+			prometheus.GaugeValue,
+			float64(len(releases)),
+			repo,
+		)
+		var processingStart = time.Now()
+		res, err := getLatest(repo, releases, settings, c.options)
+		if err != nil {
+			processing += time.Since(processingStart)
+			log.Errorf("failed to collect for %s: %s", repo, err.Error())
+			success = false
+			continue
+		}
+		if c.options.DurationAlpha > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				c.durationEWMA,
+				prometheus.GaugeValue,
+				c.ewma(repo, time.Since(repoStart)),
+				repo,
+			)
+		}
+		if settings.MinVersion != "" || settings.MaxVersion != "" {
+			ch <- prometheus.MustNewConstMetric(
+				c.outOfWindow,
+				prometheus.GaugeValue,
+				float64(res.outOfWindow),
+				repo,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.outOfConstraint,
+			prometheus.GaugeValue,
+			float64(outOfConstraint(res.versions, sconstraint)),
+			repo,
+		)
+		if settings.SecurityOnly || settings.SecurityMarker != "" {
+			var newest = newestMatching(res.security, func(*semver.Version) bool { return true })
+			ch <- prometheus.MustNewConstMetric(
+				c.securityAvailable,
+				prometheus.GaugeValue,
+				boolToFloat(newest != nil && !sconstraint.Check(newest)),
+				repo,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.releasesFound,
+			prometheus.GaugeValue,
+			float64(res.found),
+			repo,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.majorVersions,
+			prometheus.GaugeValue,
+			float64(res.majors),
+			repo,
+		)
+		if settings.PublishedWithin > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				c.noRecentRelease,
+				prometheus.GaugeValue,
+				boolToFloat(res.latest == nil),
+				repo,
+			)
+		}
+		if settings.ExpectedReleaseInterval > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				c.releaseOverdue,
+				prometheus.GaugeValue,
+				boolToFloat(res.newestPublishedAt.IsZero() || time.Since(res.newestPublishedAt) > settings.ExpectedReleaseInterval),
+				repo,
+			)
+		}
+		var version = res.latest
 		if version == nil {
+			processing += time.Since(processingStart)
 			continue
 		}
+		if len(settings.Forks) > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				c.forkInfo,
+				prometheus.GaugeValue,
+				1,
+				repo,
+				origins[res.latestTag],
+			)
+		}
+		if c.options.DownloadCount {
+			ch <- prometheus.MustNewConstMetric(
+				c.downloadCount,
+				prometheus.GaugeValue,
+				float64(res.latestDownloads),
+				repo,
+			)
+		}
+		// prereleases are looked for relative to the line the constraint
+		// is on, which is the latest stable one when it matches none
+		var current = newestMatching(res.versions, sconstraint.Check)
+		if current == nil {
+			current = version
+		}
+		if next := newestMatching(res.prereleases, func(v *semver.Version) bool {
+			return v.Major() > current.Major()
+		}); next != nil {
+			ch <- prometheus.MustNewConstMetric(
+				c.nextMajorPrerelease,
+				prometheus.GaugeValue,
+				1,
+				repo,
+				next.String(),
+				res.tags[next],
+			)
+		}
+		if next := newestMatching(res.prereleases, func(v *semver.Version) bool {
+			return v.Major() == current.Major() && v.Minor() == current.Minor() && v.GreaterThan(current)
+		}); next != nil {
+			ch <- prometheus.MustNewConstMetric(
+				c.linePrerelease,
+				prometheus.GaugeValue,
+				1,
+				repo,
+				next.String(),
+				res.tags[next],
+			)
+		}
 		var up = sconstraint.Check(version)
+		if settings.Mode == modeNewestBuild {
+			up = up && currentBuildIsLatest(constraint, version)
+		}
+		processing += time.Since(processingStart)
 		log.With("constraint", constraint).
 			With("latest", version).
 			With("up_to_date", up).
@@ -93,6 +500,13 @@ func (c *versionCollector) Collect(ch chan<- prometheus.Metric) {
 			repo,
 			constraint,
 			version.String(),
+			res.latestTag,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.upToDateStreak,
+			prometheus.GaugeValue,
+			c.streak(repo, up).Seconds(),
+			repo,
 		)
 	}
 
@@ -106,33 +520,782 @@ func (c *versionCollector) Collect(ch chan<- prometheus.Metric) {
 		prometheus.GaugeValue,
 		time.Since(start).Seconds(),
 	)
+	ch <- prometheus.MustNewConstMetric(
+		c.processingDuration,
+		prometheus.GaugeValue,
+		processing.Seconds(),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.configInfo,
+		prometheus.GaugeValue,
+		1,
+		c.config.Hash,
+	)
+}
+
+// prune forgets the repositories which were removed from the config.
+func (c *versionCollector) prune() {
+	for repo := range c.upToDateSince {
+		if _, ok := c.config.Repositories[repo]; !ok {
+			delete(c.upToDateSince, repo)
+		}
+	}
+	for repo := range c.durations {
+		if _, ok := c.config.Repositories[repo]; !ok {
+			delete(c.durations, repo)
+		}
+	}
+}
+
+// streak records whether the repository is up to date and returns for how
+// long it has been so.
+func (c *versionCollector) streak(repo string, up bool) time.Duration {
+	if !up {
+		delete(c.upToDateSince, repo)
+		return 0
+	}
+	since, ok := c.upToDateSince[repo]
+	if !ok {
+		since = time.Now()
+		c.upToDateSince[repo] = since
+	}
+	return time.Since(since)
+}
+
+// ewma records how long collecting the repository took and returns the
+// updated moving average in seconds.
+func (c *versionCollector) ewma(repo string, duration time.Duration) float64 {
+	var value = duration.Seconds()
+	if previous, ok := c.durations[repo]; ok {
+		value = c.options.DurationAlpha*value + (1-c.options.DurationAlpha)*previous
+	}
+	c.durations[repo] = value
+	return value
+}
+
+// clientFor returns the client of the repository provider.
+func (c *versionCollector) clientFor(settings config.Repository) (client.Client, error) {
+	switch settings.Provider {
+	case "", providerGithub:
+		return c.client, nil
+	case providerExec:
+		cli, ok := c.options.Commands[settings.Command]
+		if !ok {
+			return nil, fmt.Errorf("command not allowed: %s", settings.Command)
+		}
+		return cli, nil
+	case providerService:
+		if settings.URL == "" {
+			return nil, fmt.Errorf("the service provider requires an url")
+		}
+		if c.options.Service == nil {
+			return nil, fmt.Errorf("the service provider is not enabled")
+		}
+		return c.httpClient(fmt.Sprint(settings.Provider, settings.Headers), func() client.Client {
+			return c.options.Service(settings.Headers)
+		}), nil
+	case providerHomebrew:
+		if c.options.Homebrew == nil {
+			return nil, fmt.Errorf("the homebrew provider is not enabled")
+		}
+		return c.options.Homebrew, nil
+	case providerJSON:
+		if settings.URL == "" {
+			return nil, fmt.Errorf("the json provider requires an url")
+		}
+		if c.options.JSON == nil {
+			return nil, fmt.Errorf("the json provider is not enabled")
+		}
+		var fields = client.JSONFields{
+			VersionsPath:    settings.VersionsPath,
+			VersionField:    settings.VersionField,
+			PrereleaseField: settings.PrereleaseField,
+		}
+		return c.httpClient(fmt.Sprint(settings.Provider, fields, settings.Headers), func() client.Client {
+			return c.options.JSON(fields, settings.Headers)
+		}), nil
+	default:
+		return nil, fmt.Errorf("invalid provider: %s", settings.Provider)
+	}
+}
+
+// httpClient returns the client with the given key, creating it if needed.
+func (c *versionCollector) httpClient(key string, create func() client.Client) client.Client {
+	if c.httpClients == nil {
+		c.httpClients = map[string]client.Client{}
+	}
+	cli, ok := c.httpClients[key]
+	if !ok {
+		cli = create()
+		c.httpClients[key] = cli
+	}
+	return cli
+}
+
+// prefetch lets the github client get at once the releases of all the
+// repositories, and their forks, about to be collected from it, when it
+// supports it.
+func (c *versionCollector) prefetch() {
+	prefetcher, ok := c.client.(client.Prefetcher)
+	if !ok {
+		return
+	}
+	var repos []string
+	for repo, settings := range c.config.Repositories {
+		if settings.Provider != "" && settings.Provider != providerGithub {
+			continue
+		}
+		switch settings.Source {
+		case "", sourceReleases, sourceAuto:
+			repos = append(repos, repo)
+			repos = append(repos, settings.Forks...)
+		}
+	}
+	sort.Strings(repos)
+	prefetcher.Prefetch(repos)
+}
+
+// constraint returns the constraint of the repository, which is the version
+// pinned in its constraint file when there is one.
+func (c *versionCollector) constraint(settings config.Repository) (string, error) {
+	if settings.ConstraintFileURL == "" {
+		return settings.Constraint, nil
+	}
+	if settings.ConstraintFileTool == "" {
+		return "", fmt.Errorf("constraint_file_tool is required with constraint_file_url")
+	}
+	if c.options.Pins == nil {
+		return "", fmt.Errorf("constraint files are not enabled")
+	}
+	return c.options.Pins.Pinned(settings.ConstraintFileURL, settings.ConstraintFileTool)
+}
+
+// params returns the info metric of the effective repository settings.
+func (c *versionCollector) params(repo string, settings config.Repository) prometheus.Metric {
+	var provider, source, mode = settings.Provider, settings.Source, settings.Mode
+	if provider == "" {
+		provider = providerGithub
+	}
+	if source == "" {
+		source = sourceReleases
+	}
+	if mode == "" {
+		mode = modeLatest
+	}
+	return prometheus.MustNewConstMetric(
+		c.paramsInfo,
+		prometheus.GaugeValue,
+		1,
+		repo,
+		settings.Constraint,
+		provider,
+		source,
+		mode,
+		fmt.Sprint(c.options.LenientSemver),
+	)
 }
 
-func getLatest(client client.Client, repo string) (*semver.Version, error) {
+// result holds what was found for a repository.
+type result struct {
+	latest            *semver.Version
+	latestTag         string
+	newestPublishedAt time.Time
+	latestDownloads   int
+	versions          []*semver.Version
+	prereleases       []*semver.Version
+	security          []*semver.Version
+	tags              map[*semver.Version]string
+	outOfWindow       int
+	majors            int
+	found             int
+}
+
+func getLatest(repo string, releases []client.Release, settings config.Repository, options Options) (result, error) {
 	var log = log.With("repo", repo)
-	releases, err := client.Releases(repo)
+	var res result
+	window, err := newWindow(settings)
 	if err != nil {
-		return nil, err
+		return res, err
+	}
+	releases, err = filterByName(releases, settings)
+	if err != nil {
+		return res, err
 	}
+	releases, err = filterByLabel(releases, settings)
+	if err != nil {
+		return res, err
+	}
+	releases = filterByPrefix(releases, settings)
+	if settings.ExpectedReleaseInterval > 0 {
+		if err := unsupported("expected_release_interval", settings); err != nil {
+			return res, err
+		}
+	}
+	// before filtering by publish date, so releases published too long ago
+	// still count as the last ones
+	res.newestPublishedAt = newestPublished(releases)
+	releases, err = filterByPublished(releases, settings, time.Now())
+	if err != nil {
+		return res, err
+	}
+	releases, err = filterByRequire(releases, settings)
+	if err != nil {
+		return res, err
+	}
+	var versions []*semver.Version
+	var tags = map[*semver.Version]string{}
+	var downloads = map[*semver.Version]int{}
+	var majors = map[int64]bool{}
 	for _, release := range releases {
-		if release.Draft || release.Prerelease {
-			log.With("tag", release.TagName).Debug("ignored draft/prerelease")
+		if release.Draft {
+			log.With("tag", release.TagName).Debug("ignored draft")
+			continue
+		}
+		version, err := parseVersion(release.TagName, options.LenientSemver)
+		if err != nil && release.Prerelease {
+			// prereleases used to be ignored before being parsed, and
+			// are only looked at for the prerelease metrics anyway
+			log.With("error", err).
+				With("tag", release.TagName).
+				Debug("ignored unparseable prerelease")
 			continue
 		}
-		version, err := semver.NewVersion(release.TagName)
 		if err != nil {
 			log.With("error", err).
 				With("tag", release.TagName).
 				Errorf("failed to parse tag %s", release.TagName)
 			continue
 		}
-		if version.Prerelease() != "" {
+		if release.Prerelease || version.Prerelease() != "" {
 			log.With("tag", release.TagName).Debug("ignored prerelease")
+			res.prereleases = append(res.prereleases, version)
+			tags[version] = release.TagName
+			continue
+		}
+		majors[version.Major()] = true
+		if !window.contains(version) {
+			log.With("tag", release.TagName).Debug("ignored release out of window")
+			res.outOfWindow++
+			continue
+		}
+		if isSecurity(release, settings) {
+			res.security = append(res.security, version)
+		} else if settings.SecurityOnly {
+			log.With("tag", release.TagName).Debug("ignored non security release")
+			continue
+		}
+		versions = append(versions, version)
+		tags[version] = release.TagName
+		for _, asset := range release.Assets {
+			downloads[version] += asset.DownloadCount
+		}
+	}
+	res.majors = len(majors)
+	res.found = len(versions)
+	res.versions = versions
+	res.tags = tags
+	res.latest, err = selectLatest(versions, settings)
+	res.latestTag = tags[res.latest]
+	res.latestDownloads = downloads[res.latest]
+	return res, err
+}
+
+// nolint: gochecknoglobals
+var semverRegex = regexp.MustCompile(`\d+\.\d+\.\d+(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?`)
+
+// parseVersion parses the given tag. When lenient, malformed tags like
+// version-v-1.2.3 or 1.2.3v are parsed from their first semver-looking part.
+func parseVersion(tag string, lenient bool) (*semver.Version, error) {
+	version, err := semver.NewVersion(tag)
+	if err == nil || !lenient {
+		return version, err
+	}
+	var extracted = semverRegex.FindString(tag)
+	if extracted == "" {
+		return nil, err
+	}
+	log.With("tag", tag).
+		With("extracted", extracted).
+		Debug("leniently extracted version from tag")
+	return semver.NewVersion(extracted)
+}
+
+// selectLatest picks the latest version according to the configured mode.
+// By default, it is the first one, as releases are listed from the newest to
+// the oldest, unless they might not be ordered, see unordered.
+func selectLatest(versions []*semver.Version, settings config.Repository) (*semver.Version, error) {
+	switch settings.Mode {
+	case "", modeLatest:
+		if len(versions) == 0 {
+			return nil, nil
+		}
+		var latest = versions[0]
+		for _, version := range versions[1:] {
+			if unordered(settings) && version.GreaterThan(latest) ||
+				settings.CompareBuildMetadata && newerBuild(version, latest) {
+				latest = version
+			}
+		}
+		return latest, nil
+	case modeReleaseBranch:
+		return latestOfHighestBranch(versions, settings.CompareBuildMetadata), nil
+	case modeNewestBuild:
+		var latest *semver.Version
+		for _, version := range versions {
+			if latest == nil || version.GreaterThan(latest) || newestBuild(version, latest) {
+				latest = version
+			}
+		}
+		return latest, nil
+	default:
+		return nil, fmt.Errorf("invalid mode: %s", settings.Mode)
+	}
+}
+
+// unordered reports whether the releases are not necessarily ordered from the
+// newest to the oldest, so the highest one has to be found: merged fork
+// releases, json api ones, and tags, which github sorts by name, e.g. v1.9.0
+// before v1.10.0. Auto repositories which fell back to tags have the tags
+// source by then.
+func unordered(settings config.Repository) bool {
+	return len(settings.Forks) > 0 || settings.Provider == providerJSON || settings.Source == sourceTags
+}
+
+// newerBuild reports whether both versions are otherwise equal, but version
+// has a lexically greater build metadata than other.
+// This is not semver compliant, so it is only used when configured.
+func newerBuild(version, other *semver.Version) bool {
+	return version.Equal(other) && version.Metadata() > other.Metadata()
+}
+
+// newestBuild reports whether both versions are otherwise equal, but version
+// has a greater build metadata than other, as compared by compareBuild.
+// It is only used in the newest-build mode.
+func newestBuild(version, other *semver.Version) bool {
+	return version.Equal(other) && compareBuild(version.Metadata(), other.Metadata()) > 0
+}
+
+// compareBuild compares build metadata identifier by identifier, the same
+// way semver compares prereleases: numeric identifiers numerically, and the
+// others lexically.
+func compareBuild(a, b string) int {
+	var as, bs = strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.ParseUint(as[i], 10, 64)
+		bn, berr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aerr == nil && berr == nil && an != bn:
+			if an > bn {
+				return 1
+			}
+			return -1
+		case (aerr != nil || berr != nil) && as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// currentBuildIsLatest reports whether the constraint, when it is an exact
+// version, has the same build metadata as the latest version.
+// Constraints ignore build metadata, so it has to be checked apart.
+func currentBuildIsLatest(constraint string, latest *semver.Version) bool {
+	current, err := semver.NewVersion(constraint)
+	if err != nil || current.Metadata() == "" {
+		return true
+	}
+	return !newestBuild(latest, current)
+}
+
+// window holds the optional minimum and maximum versions to consider.
+type window struct {
+	min, max *semver.Version
+}
+
+func newWindow(settings config.Repository) (window, error) {
+	var w window
+	var err error
+	if settings.MinVersion != "" {
+		if w.min, err = semver.NewVersion(settings.MinVersion); err != nil {
+			return w, fmt.Errorf("invalid min_version: %s", err)
+		}
+	}
+	if settings.MaxVersion != "" {
+		if w.max, err = semver.NewVersion(settings.MaxVersion); err != nil {
+			return w, fmt.Errorf("invalid max_version: %s", err)
+		}
+	}
+	return w, nil
+}
+
+func (w window) contains(version *semver.Version) bool {
+	return (w.min == nil || !version.LessThan(w.min)) &&
+		(w.max == nil || !version.GreaterThan(w.max))
+}
+
+// nolint: gochecknoglobals
+var changelogHeadingRegex = regexp.MustCompile(`(?m)^##\s*\[([^\]]+)\]`)
+
+// onlyTagNames reports whether the source returns nothing but tag names,
+// without any other release information.
+func onlyTagNames(source string) bool {
+	return source == sourceTags || source == sourceChangelog
+}
+
+// onlyVersions reports whether the provider returns nothing but versions,
+// without any other release information.
+func onlyVersions(provider string) bool {
+	switch provider {
+	case providerExec, providerService, providerHomebrew, providerJSON:
+		return true
+	}
+	return false
+}
+
+// unsupported returns an error when the repository source or provider
+// returns nothing but versions, as the given option needs more release
+// information.
+func unsupported(option string, settings config.Repository) error {
+	if onlyTagNames(settings.Source) {
+		return fmt.Errorf("%s can't be used with the %s source", option, settings.Source)
+	}
+	if onlyVersions(settings.Provider) {
+		return fmt.Errorf("%s can't be used with the %s provider", option, settings.Provider)
+	}
+	return nil
+}
+
+// findAutoReleases returns the releases of the given repository, or its tags
+// if it has no releases, and which of both sources answered.
+func findAutoReleases(cli client.Client, repo string) ([]client.Release, string, error) {
+	releases, err := findReleases(cli, repo, sourceReleases)
+	if err != nil || len(releases) > 0 {
+		return releases, sourceReleases, err
+	}
+	log.With("repo", repo).Debug("no releases found, falling back to tags")
+	releases, err = findReleases(cli, repo, sourceTags)
+	return releases, sourceTags, err
+}
+
+// findReleases returns the releases of the given repository, using the
+// repository tags or changelog entries as releases when the tags or the
+// changelog sources are configured.
+func findReleases(cli client.Client, repo, source string) ([]client.Release, error) {
+	switch source {
+	case "", sourceReleases:
+		return cli.Releases(repo)
+	case sourceTags:
+		tags, err := cli.Tags(repo)
+		if err != nil {
+			return nil, err
+		}
+		var releases = make([]client.Release, 0, len(tags))
+		for _, tag := range tags {
+			releases = append(releases, client.Release{TagName: tag.Name})
+		}
+		return releases, nil
+	case sourceChangelog:
+		changelog, err := cli.Changelog(repo)
+		if err != nil {
+			return nil, err
+		}
+		var releases []client.Release
+		for _, match := range changelogHeadingRegex.FindAllStringSubmatch(changelog, -1) {
+			if strings.EqualFold(match[1], "unreleased") {
+				continue
+			}
+			releases = append(releases, client.Release{TagName: match[1]})
+		}
+		return releases, nil
+	default:
+		return nil, fmt.Errorf("invalid source: %s", source)
+	}
+}
+
+// mergeForks appends the releases of the configured forks to the ones of the
+// repository, and returns from which of them each tag came. Forks that fail
+// are only logged, as the repository and the other forks may still answer.
+//
+// Forks are fetched concurrently, up to maxConcurrentForks at a time, but
+// merged in the configured order, so the result does not depend on which
+// answers first.
+func mergeForks(cli client.Client, repo string, releases []client.Release, settings config.Repository) ([]client.Release, map[string]string) {
+	var origins = map[string]string{}
+	for _, release := range releases {
+		origins[release.TagName] = repo
+	}
+	var results = make([][]client.Release, len(settings.Forks))
+	var sem = make(chan struct{}, maxConcurrentForks)
+	var wg sync.WaitGroup
+	for i, fork := range settings.Forks {
+		wg.Add(1)
+		go func(i int, fork string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			forked, err := findReleases(cli, fork, settings.Source)
+			if err != nil {
+				log.With("repo", repo).
+					With("fork", fork).
+					Warnf("failed to get fork releases: %s", err)
+				return
+			}
+			results[i] = forked
+		}(i, fork)
+	}
+	wg.Wait()
+	for i, fork := range settings.Forks {
+		for _, release := range results[i] {
+			if _, ok := origins[release.TagName]; ok {
+				continue
+			}
+			origins[release.TagName] = fork
+			releases = append(releases, release)
+		}
+	}
+	return releases, origins
+}
+
+// filterByName keeps only the releases whose name matches the configured
+// release name regex. Tags, changelog entries and the versions of the other
+// providers have no names, so the filter does not apply to them.
+func filterByName(releases []client.Release, settings config.Repository) ([]client.Release, error) {
+	if settings.ReleaseNameRegex == "" || onlyTagNames(settings.Source) || onlyVersions(settings.Provider) {
+		return releases, nil
+	}
+	re, err := regexp.Compile(settings.ReleaseNameRegex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid release_name_regex: %s", err)
+	}
+	var result []client.Release
+	for _, release := range releases {
+		if release.Name == "" || !re.MatchString(release.Name) {
+			log.With("tag", release.TagName).
+				With("name", release.Name).
+				Debug("ignored release not matching release_name_regex")
+			continue
+		}
+		result = append(result, release)
+	}
+	return result, nil
+}
+
+// filterByLabel keeps only the releases of the configured channel label,
+// looked up in the release name by default. Tags, changelog entries and the
+// versions of the other providers are always matched by their version.
+// Labeled drafts and prereleases are still ignored afterwards.
+func filterByLabel(releases []client.Release, settings config.Repository) ([]client.Release, error) {
+	if settings.Label == "" {
+		return releases, nil
+	}
+	var field = settings.LabelField
+	if onlyTagNames(settings.Source) || onlyVersions(settings.Provider) {
+		field = labelFieldTag
+	}
+	var label = strings.ToLower(settings.Label)
+	var result []client.Release
+	for _, release := range releases {
+		var value string
+		switch field {
+		case "", labelFieldName:
+			value = release.Name
+		case labelFieldTag:
+			value = release.TagName
+		default:
+			return nil, fmt.Errorf("invalid label_field: %s", field)
+		}
+		if !strings.Contains(strings.ToLower(value), label) {
+			log.With("tag", release.TagName).
+				With("label", settings.Label).
+				Debug("ignored release without label")
+			continue
+		}
+		result = append(result, release)
+	}
+	return result, nil
+}
+
+// filterByPrefix removes the releases whose tag starts with any of the
+// excluded prefixes, e.g. tags of other components of a monorepo.
+func filterByPrefix(releases []client.Release, settings config.Repository) []client.Release {
+	if len(settings.ExcludePrefix) == 0 {
+		return releases
+	}
+	var result []client.Release
+	for _, release := range releases {
+		if hasAnyPrefix(release.TagName, settings.ExcludePrefix) {
+			log.With("tag", release.TagName).Debug("ignored release with excluded prefix")
+			continue
+		}
+		result = append(result, release)
+	}
+	return result
+}
+
+// newestPublished returns when the newest stable release was published, the
+// zero time if there is none.
+func newestPublished(releases []client.Release) time.Time {
+	var newest time.Time
+	for _, release := range releases {
+		if release.Draft || release.Prerelease {
+			continue
+		}
+		if release.PublishedAt.After(newest) {
+			newest = release.PublishedAt
+		}
+	}
+	return newest
+}
+
+// filterByPublished keeps only the releases published within the configured
+// duration before now. Tags, changelog entries and the versions of the other
+// providers have no publish date, so they can't be filtered.
+func filterByPublished(releases []client.Release, settings config.Repository, now time.Time) ([]client.Release, error) {
+	if settings.PublishedWithin <= 0 {
+		return releases, nil
+	}
+	if err := unsupported("published_within", settings); err != nil {
+		return nil, err
+	}
+	var since = now.Add(-settings.PublishedWithin)
+	var result []client.Release
+	for _, release := range releases {
+		if release.PublishedAt.Before(since) {
+			log.With("tag", release.TagName).
+				With("published_at", formatTime(release.PublishedAt)).
+				Debug("ignored release published too long ago")
 			continue
 		}
-		return version, nil
+		result = append(result, release)
+	}
+	return result, nil
+}
+
+// filterByRequire keeps only the releases meeting all the configured
+// requirements, which weeds out placeholder releases. Tags, changelog entries
+// and the versions of the other providers have neither assets nor bodies, so
+// they can't be filtered.
+func filterByRequire(releases []client.Release, settings config.Repository) ([]client.Release, error) {
+	if len(settings.Require) == 0 {
+		return releases, nil
+	}
+	if err := unsupported("require", settings); err != nil {
+		return nil, err
+	}
+	for _, requirement := range settings.Require {
+		switch requirement {
+		case requireAssets, requireBody, requireNonDraft:
+		default:
+			return nil, fmt.Errorf("invalid requirement: %s", requirement)
+		}
+	}
+	var result []client.Release
+	for _, release := range releases {
+		if missing := missingRequirement(release, settings.Require); missing != "" {
+			log.With("tag", release.TagName).
+				With("requirement", missing).
+				Debug("ignored release not meeting a requirement")
+			continue
+		}
+		result = append(result, release)
+	}
+	return result, nil
+}
+
+// missingRequirement returns the first requirement the release does not meet,
+// if any.
+func missingRequirement(release client.Release, requirements []string) string {
+	for _, requirement := range requirements {
+		switch {
+		case requirement == requireAssets && len(release.Assets) == 0,
+			requirement == requireBody && strings.TrimSpace(release.Body) == "",
+			requirement == requireNonDraft && release.Draft:
+			return requirement
+		}
+	}
+	return ""
+}
+
+// formatTime formats times the same way wherever they are shown, regardless
+// of the server's local timezone.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// latestOfHighestBranch groups the versions by their release branch
+// (major.minor) and returns the highest patch of the highest branch.
+func latestOfHighestBranch(versions []*semver.Version, compareBuildMetadata bool) *semver.Version {
+	var branches = map[string]*semver.Version{}
+	var highest *semver.Version
+	for _, version := range versions {
+		var branch = fmt.Sprintf("%d.%d", version.Major(), version.Minor())
+		if latest, ok := branches[branch]; !ok ||
+			version.Patch() > latest.Patch() ||
+			compareBuildMetadata && newerBuild(version, latest) {
+			branches[branch] = version
+		}
+		if highest == nil ||
+			version.Major() > highest.Major() ||
+			version.Major() == highest.Major() && version.Minor() > highest.Minor() {
+			highest = version
+		}
+	}
+	if highest == nil {
+		return nil
+	}
+	return branches[fmt.Sprintf("%d.%d", highest.Major(), highest.Minor())]
+}
+
+// isSecurity reports whether the release is a security one, that is, whether
+// its name or tag contains the security marker, case-insensitively.
+func isSecurity(release client.Release, settings config.Repository) bool {
+	var marker = settings.SecurityMarker
+	if marker == "" {
+		marker = defaultSecurityMarker
+	}
+	marker = strings.ToLower(marker)
+	return strings.Contains(strings.ToLower(release.Name), marker) ||
+		strings.Contains(strings.ToLower(release.TagName), marker)
+}
+
+// newestMatching returns the newest of the versions matching the given
+// predicate, if any.
+func newestMatching(versions []*semver.Version, matches func(*semver.Version) bool) *semver.Version {
+	var newest *semver.Version
+	for _, version := range versions {
+		if matches(version) && (newest == nil || version.GreaterThan(newest)) {
+			newest = version
+		}
+	}
+	return newest
+}
+
+// outOfConstraint counts the versions that do not satisfy the constraint
+// and are newer than all the ones that do, that is, the upgrades that would
+// require changing it.
+func outOfConstraint(versions []*semver.Version, constraint *semver.Constraints) int {
+	var newest *semver.Version
+	for _, version := range versions {
+		if constraint.Check(version) && (newest == nil || version.GreaterThan(newest)) {
+			newest = version
+		}
+	}
+	var count int
+	for _, version := range versions {
+		if !constraint.Check(version) && (newest == nil || version.GreaterThan(newest)) {
+			count++
+		}
 	}
-	return nil, nil
+	return count
 }
 
 func boolToFloat(b bool) float64 {
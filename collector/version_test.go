@@ -6,22 +6,25 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/caarlos0/version_exporter/client"
 	"github.com/caarlos0/version_exporter/config"
+	"github.com/patrickmn/go-cache"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
 func TestCollectorError(t *testing.T) {
 	var config = config.Config{
-		Repositories: map[string]string{
-			"foo": "v0.1.1",
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "v0.1.1"},
 		},
 	}
 	var client = client.NewFakeClient([]client.Release{}, fmt.Errorf("failed to blah"))
-	testCollector(t, NewVersionCollector(&config, client), func(t *testing.T, status int, body string) {
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
 		require.Equal(t, 200, status)
 		require.Contains(t, body, "version_up 0")
 	})
@@ -29,8 +32,8 @@ func TestCollectorError(t *testing.T) {
 
 func TestRepoUpToDate(t *testing.T) {
 	var config = config.Config{
-		Repositories: map[string]string{
-			"foo": "v0.1.1",
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "v0.1.1"},
 		},
 	}
 	var client = client.NewFakeClient([]client.Release{
@@ -38,17 +41,18 @@ func TestRepoUpToDate(t *testing.T) {
 			TagName: "v0.1.1",
 		},
 	}, nil)
-	testCollector(t, NewVersionCollector(&config, client), func(t *testing.T, status int, body string) {
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
 		require.Equal(t, 200, status)
 		require.Contains(t, body, "version_up 1")
-		require.Contains(t, body, `version_up_to_date{constraint="v0.1.1",latest="0.1.1",repository="foo"} 1`)
+		require.Contains(t, body, `version_up_to_date{constraint="v0.1.1",latest="0.1.1",latest_raw_tag="v0.1.1",repository="foo"} 1`)
+		require.Contains(t, body, "version_processing_duration_seconds ")
 	})
 }
 
 func TestRepoOutOfDate(t *testing.T) {
 	var config = config.Config{
-		Repositories: map[string]string{
-			"foo": "v0.1.1",
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "v0.1.1"},
 		},
 	}
 	var client = client.NewFakeClient([]client.Release{
@@ -56,17 +60,17 @@ func TestRepoOutOfDate(t *testing.T) {
 			TagName: "v0.1.2",
 		},
 	}, nil)
-	testCollector(t, NewVersionCollector(&config, client), func(t *testing.T, status int, body string) {
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
 		require.Equal(t, 200, status)
 		require.Contains(t, body, "version_up 1")
-		require.Contains(t, body, `version_up_to_date{constraint="v0.1.1",latest="0.1.2",repository="foo"} 0`)
+		require.Contains(t, body, `version_up_to_date{constraint="v0.1.1",latest="0.1.2",latest_raw_tag="v0.1.2",repository="foo"} 0`)
 	})
 }
 
 func TestDraftRelease(t *testing.T) {
 	var config = config.Config{
-		Repositories: map[string]string{
-			"foo": "v0.1.1",
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "v0.1.1"},
 		},
 	}
 	var client = client.NewFakeClient([]client.Release{
@@ -78,17 +82,17 @@ func TestDraftRelease(t *testing.T) {
 			TagName: "v0.1.1",
 		},
 	}, nil)
-	testCollector(t, NewVersionCollector(&config, client), func(t *testing.T, status int, body string) {
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
 		require.Equal(t, 200, status)
 		require.Contains(t, body, "version_up 1")
-		require.Contains(t, body, `version_up_to_date{constraint="v0.1.1",latest="0.1.1",repository="foo"} 1`)
+		require.Contains(t, body, `version_up_to_date{constraint="v0.1.1",latest="0.1.1",latest_raw_tag="v0.1.1",repository="foo"} 1`)
 	})
 }
 
 func TestPrerelease(t *testing.T) {
 	var config = config.Config{
-		Repositories: map[string]string{
-			"foo": "v0.1.1",
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "v0.1.1"},
 		},
 	}
 	var client = client.NewFakeClient([]client.Release{
@@ -100,17 +104,17 @@ func TestPrerelease(t *testing.T) {
 			TagName: "v0.1.1",
 		},
 	}, nil)
-	testCollector(t, NewVersionCollector(&config, client), func(t *testing.T, status int, body string) {
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
 		require.Equal(t, 200, status)
 		require.Contains(t, body, "version_up 1")
-		require.Contains(t, body, `version_up_to_date{constraint="v0.1.1",latest="0.1.1",repository="foo"} 1`)
+		require.Contains(t, body, `version_up_to_date{constraint="v0.1.1",latest="0.1.1",latest_raw_tag="v0.1.1",repository="foo"} 1`)
 	})
 }
 
 func TestTagWithPrelease(t *testing.T) {
 	var config = config.Config{
-		Repositories: map[string]string{
-			"foo": "v0.1.1",
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "v0.1.1"},
 		},
 	}
 	var client = client.NewFakeClient([]client.Release{
@@ -121,17 +125,17 @@ func TestTagWithPrelease(t *testing.T) {
 			TagName: "v0.1.1",
 		},
 	}, nil)
-	testCollector(t, NewVersionCollector(&config, client), func(t *testing.T, status int, body string) {
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
 		require.Equal(t, 200, status)
 		require.Contains(t, body, "version_up 1")
-		require.Contains(t, body, `version_up_to_date{constraint="v0.1.1",latest="0.1.1",repository="foo"} 1`)
+		require.Contains(t, body, `version_up_to_date{constraint="v0.1.1",latest="0.1.1",latest_raw_tag="v0.1.1",repository="foo"} 1`)
 	})
 }
 
 func TestInvalidConstraintOnConfig(t *testing.T) {
 	var config = config.Config{
-		Repositories: map[string]string{
-			"foo": "invalid-tag-on-config",
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "invalid-tag-on-config"},
 		},
 	}
 	var client = client.NewFakeClient([]client.Release{
@@ -139,7 +143,7 @@ func TestInvalidConstraintOnConfig(t *testing.T) {
 			TagName: "v0.1.1",
 		},
 	}, nil)
-	testCollector(t, NewVersionCollector(&config, client), func(t *testing.T, status int, body string) {
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
 		require.Equal(t, 200, status)
 		require.Contains(t, body, "version_up 0")
 	})
@@ -147,8 +151,8 @@ func TestInvalidConstraintOnConfig(t *testing.T) {
 
 func TestInvalidSemVerOnRelease(t *testing.T) {
 	var config = config.Config{
-		Repositories: map[string]string{
-			"foo": "1.2.0",
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "1.2.0"},
 		},
 	}
 	var client = client.NewFakeClient([]client.Release{
@@ -156,12 +160,1341 @@ func TestInvalidSemVerOnRelease(t *testing.T) {
 			TagName: "invalid-tag-on-release",
 		},
 	}, nil)
-	testCollector(t, NewVersionCollector(&config, client), func(t *testing.T, status int, body string) {
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
 		require.Equal(t, 200, status)
 		require.Contains(t, body, "version_up 1")
 	})
 }
 
+func TestTagsSource(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "v0.1.1", Source: "tags"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{
+			TagName: "v0.1.2",
+			Draft:   true,
+		},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 1")
+		require.Contains(t, body, `version_up_to_date{constraint="v0.1.1",latest="0.1.2",latest_raw_tag="v0.1.2",repository="foo"} 0`)
+	})
+}
+
+func TestTagsSourceUnordered(t *testing.T) {
+	var tags = []client.Tag{
+		{Name: "v1.9.0"},
+		{Name: "v1.10.0"},
+		{Name: "v1.2.0"},
+	}
+	for _, source := range []string{"tags", "auto"} {
+		source := source
+		t.Run(source, func(t *testing.T) {
+			var config = config.Config{
+				Repositories: map[string]config.Repository{
+					"foo": {Constraint: "~1.10.0", Source: source},
+				},
+			}
+			var client = onlyTagsClient{tags: tags}
+			testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+				require.Equal(t, 200, status)
+				require.Contains(t, body, `version_up_to_date{constraint="~1.10.0",latest="1.10.0",latest_raw_tag="v1.10.0",repository="foo"} 1`)
+			})
+		})
+	}
+}
+
+func TestChangelogSource(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0", Source: "changelog"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "Unreleased"},
+		{TagName: "1.3.0"},
+		{TagName: "1.2.0"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 1")
+		require.Contains(t, body, `version_up_to_date{constraint="~1.2.0",latest="1.3.0",latest_raw_tag="1.3.0",repository="foo"} 0`)
+	})
+}
+
+func TestAutoSource(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0", Source: "auto"},
+		},
+	}
+	t.Run("releases", func(t *testing.T) {
+		var client = client.NewFakeClient([]client.Release{{TagName: "v1.2.0"}}, nil)
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, `version_source_info{repository="foo",source="releases"} 1`)
+			require.Contains(t, body, `version_up_to_date{constraint="~1.2.0",latest="1.2.0",latest_raw_tag="v1.2.0",repository="foo"} 1`)
+		})
+	})
+	t.Run("tags", func(t *testing.T) {
+		var client = onlyTagsClient{tags: []client.Tag{{Name: "v1.3.0"}}}
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, `version_source_info{repository="foo",source="tags"} 1`)
+			require.Contains(t, body, `version_up_to_date{constraint="~1.2.0",latest="1.3.0",latest_raw_tag="v1.3.0",repository="foo"} 0`)
+		})
+	})
+}
+
+type onlyTagsClient struct {
+	client.Client
+	tags []client.Tag
+}
+
+func (c onlyTagsClient) Releases(repo string) ([]client.Release, error) {
+	return nil, nil
+}
+
+func (c onlyTagsClient) Tags(repo string) ([]client.Tag, error) {
+	return c.tags, nil
+}
+
+func TestInvalidSource(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "v0.1.1", Source: "nope"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{
+			TagName: "v0.1.1",
+		},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 0")
+	})
+}
+
+func TestReleaseBranchMode(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.3.0", Source: "tags", Mode: "release-branch"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v1.2.9"},
+		{TagName: "v1.3.1"},
+		{TagName: "v1.1.12"},
+		{TagName: "v1.3.4"},
+		{TagName: "v1.3.2"},
+		{TagName: "v1.4.0-rc.1"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 1")
+		require.Contains(t, body, `version_up_to_date{constraint="~1.3.0",latest="1.3.4",latest_raw_tag="v1.3.4",repository="foo"} 1`)
+	})
+}
+
+func TestReleaseNameRegex(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~5.10.0", ReleaseNameRegex: `\(LTS\)$`},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v5.16.1", Name: "v5.16.1"},
+		{TagName: "v5.15.133"},
+		{TagName: "v5.15.132", Name: "v5.15.132 (LTS)"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 1")
+		require.Contains(t, body, `version_up_to_date{constraint="~5.10.0",latest="5.15.132",latest_raw_tag="v5.15.132",repository="foo"} 0`)
+	})
+}
+
+func TestReleaseNameRegexIgnoredForTags(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~5.16.0", Source: "tags", ReleaseNameRegex: "LTS"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v5.16.1"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_up_to_date{constraint="~5.16.0",latest="5.16.1",latest_raw_tag="v5.16.1",repository="foo"} 1`)
+	})
+}
+
+func TestReleaseNameRegexIgnoredForProviders(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~5.16.0", Provider: "exec", Command: "get-version", ReleaseNameRegex: "LTS"},
+			"bar": {Constraint: "~5.16.0", Provider: "exec", Command: "get-version", Label: "5.16"},
+		},
+	}
+	var options = Options{
+		Commands: map[string]client.Client{
+			"get-version": client.NewFakeClient([]client.Release{{TagName: "v5.16.1"}}, nil),
+		},
+	}
+	testCollector(t, NewVersionCollector(&config, nil, options), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 1")
+		require.Contains(t, body, `version_up_to_date{constraint="~5.16.0",latest="5.16.1",latest_raw_tag="v5.16.1",repository="foo"} 1`)
+		require.Contains(t, body, `version_up_to_date{constraint="~5.16.0",latest="5.16.1",latest_raw_tag="v5.16.1",repository="bar"} 1`)
+	})
+}
+
+func TestInvalidReleaseNameRegex(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~5.16.0", ReleaseNameRegex: "("},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v5.16.1", Name: "v5.16.1"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 0")
+	})
+}
+
+func TestConfigInfo(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{},
+		Hash:         "0123456789ab",
+	}
+	var client = client.NewFakeClient([]client.Release{}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_config_info{hash="0123456789ab"} 1`)
+	})
+}
+
+func TestVersionWindow(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~2.1.0", MinVersion: "2.0.0", MaxVersion: "2.99.99"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v3.1.0"},
+		{TagName: "v3.0.0"},
+		{TagName: "v2.2.0"},
+		{TagName: "v2.1.3"},
+		{TagName: "v1.9.0"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 1")
+		require.Contains(t, body, `version_up_to_date{constraint="~2.1.0",latest="2.2.0",latest_raw_tag="v2.2.0",repository="foo"} 0`)
+		require.Contains(t, body, `version_out_of_window_available{repository="foo"} 3`)
+	})
+}
+
+func TestInvalidVersionWindow(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~2.1.0", MinVersion: "nope"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v2.1.0"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 0")
+		require.NotContains(t, body, "version_out_of_window_available{")
+	})
+}
+
+func TestInvalidMode(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~2.1.0", Mode: "nope"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v2.1.0"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 0")
+	})
+}
+
+func TestNewestBuild(t *testing.T) {
+	var releases = []client.Release{
+		{TagName: "v1.2.3+corp.9"},
+		{TagName: "v1.2.3+corp.45"},
+		{TagName: "v1.2.2+corp.50"},
+	}
+	for constraint, up := range map[string]int{
+		"1.2.3+corp.45": 1,
+		"1.2.3+corp.9":  0,
+		"~1.2.0":        1,
+	} {
+		constraint, up := constraint, up
+		t.Run(constraint, func(t *testing.T) {
+			var config = config.Config{
+				Repositories: map[string]config.Repository{
+					"foo": {Constraint: constraint, Mode: "newest-build"},
+				},
+			}
+			var client = client.NewFakeClient(releases, nil)
+			testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+				require.Equal(t, 200, status)
+				require.Contains(t, body, fmt.Sprintf(`version_up_to_date{constraint="%s",latest="1.2.3+corp.45",latest_raw_tag="v1.2.3+corp.45",repository="foo"} %d`, constraint, up))
+			})
+		})
+	}
+}
+
+func TestCompareBuild(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		cmp  int
+	}{
+		{"corp.45", "corp.9", 1},
+		{"corp.9", "corp.45", -1},
+		{"corp.9", "corp.9", 0},
+		{"corp.9.1", "corp.9", 1},
+		{"b", "a", 1},
+		{"20240201", "20240101", 1},
+	} {
+		var cmp = compareBuild(tt.a, tt.b)
+		switch {
+		case tt.cmp > 0:
+			require.True(t, cmp > 0, "%s > %s", tt.a, tt.b)
+		case tt.cmp < 0:
+			require.True(t, cmp < 0, "%s < %s", tt.a, tt.b)
+		default:
+			require.Zero(t, cmp, "%s == %s", tt.a, tt.b)
+		}
+	}
+}
+
+func TestLabel(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0", Label: "ga"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v1.4.0", Name: "v1.4.0 GA", Prerelease: true},
+		{TagName: "v1.3.0", Name: "v1.3.0 canary"},
+		{TagName: "v1.2.1", Name: "v1.2.1 GA"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 1")
+		require.Contains(t, body, `version_up_to_date{constraint="~1.2.0",latest="1.2.1",latest_raw_tag="v1.2.1",repository="foo"} 1`)
+	})
+}
+
+func TestLabelOnTag(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0", Label: "+stable", LabelField: "tag"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v1.3.0+canary", Name: "v1.3.0"},
+		{TagName: "v1.2.1+stable", Name: "v1.2.1"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 1")
+		require.Contains(t, body, `version_up_to_date{constraint="~1.2.0",latest="1.2.1+stable",latest_raw_tag="v1.2.1+stable",repository="foo"} 1`)
+	})
+}
+
+func TestInvalidLabelField(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0", Label: "ga", LabelField: "nope"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v1.2.1", Name: "v1.2.1 GA"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 0")
+	})
+}
+
+func TestCompareBuildMetadata(t *testing.T) {
+	var releases = []client.Release{
+		{TagName: "v1.2.3+20240101"},
+		{TagName: "v1.2.3+20240201"},
+		{TagName: "v1.2.2+20240301"},
+	}
+	for name, settings := range map[string]config.Repository{
+		"default":        {Constraint: "1.2.3", CompareBuildMetadata: true},
+		"release-branch": {Constraint: "1.2.3", CompareBuildMetadata: true, Mode: "release-branch"},
+	} {
+		settings := settings
+		t.Run(name, func(t *testing.T) {
+			var config = config.Config{
+				Repositories: map[string]config.Repository{"foo": settings},
+			}
+			var client = client.NewFakeClient(releases, nil)
+			testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+				require.Equal(t, 200, status)
+				require.Contains(t, body, `version_up_to_date{constraint="1.2.3",latest="1.2.3+20240201",latest_raw_tag="v1.2.3+20240201",repository="foo"} 1`)
+			})
+		})
+	}
+}
+
+func TestCompareBuildMetadataLexically(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "1.2.3", CompareBuildMetadata: true},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v1.2.3+corp.45"},
+		{TagName: "v1.2.3+corp.9"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `latest="1.2.3+corp.9"`)
+	})
+}
+
+func TestIgnoreBuildMetadata(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "1.2.3"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v1.2.3+20240101"},
+		{TagName: "v1.2.3+20240201"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_up_to_date{constraint="1.2.3",latest="1.2.3+20240101",latest_raw_tag="v1.2.3+20240101",repository="foo"} 1`)
+	})
+}
+
+func TestMajorVersionsAvailable(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "^2.0.0"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v4.0.0-rc.1"},
+		{TagName: "v3.1.0"},
+		{TagName: "v2.9.1"},
+		{TagName: "v3.0.0"},
+		{TagName: "v2.9.0"},
+		{TagName: "v1.0.0", Draft: true},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_major_versions_available{repository="foo"} 2`)
+		require.Contains(t, body, `version_releases_fetched{repository="foo"} 6`)
+		require.Contains(t, body, `version_releases_found{repository="foo"} 4`)
+	})
+}
+
+func TestExecProvider(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0", Provider: "exec", Command: "get-version"},
+		},
+	}
+	var github = client.NewFakeClient(nil, fmt.Errorf("should not be called"))
+	var options = Options{
+		Commands: map[string]client.Client{
+			"get-version": client.NewFakeClient([]client.Release{{TagName: "1.2.4"}}, nil),
+		},
+	}
+	testCollector(t, NewVersionCollector(&config, github, options), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 1")
+		require.Contains(t, body, `version_up_to_date{constraint="~1.2.0",latest="1.2.4",latest_raw_tag="1.2.4",repository="foo"} 1`)
+	})
+}
+
+func TestParamsInfo(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0"},
+			"bar": {Constraint: "~1.2.0", Source: "tags", Mode: "release-branch"},
+			"baz": {Constraint: "~1.2.0", Provider: "nope"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{{TagName: "v1.2.4"}}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{LenientSemver: true}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_params_info{constraint="~1.2.0",lenient_semver="true",mode="latest",provider="github",repository="foo",source="releases"} 1`)
+		require.Contains(t, body, `version_params_info{constraint="~1.2.0",lenient_semver="true",mode="release-branch",provider="github",repository="bar",source="tags"} 1`)
+		require.Contains(t, body, `version_params_info{constraint="~1.2.0",lenient_semver="true",mode="latest",provider="nope",repository="baz",source="releases"} 1`)
+	})
+}
+
+func TestServiceProvider(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.18.0", Provider: "service", URL: "https://k8s.local/version"},
+		},
+	}
+	var github = client.NewFakeClient(nil, fmt.Errorf("should not be called"))
+	var options = Options{
+		Service: func(headers map[string]string) client.Client {
+			return urlClient{
+				url:    "https://k8s.local/version",
+				Client: client.NewFakeClient([]client.Release{{TagName: "v1.18.3"}}, nil),
+			}
+		},
+	}
+	testCollector(t, NewVersionCollector(&config, github, options), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 1")
+		require.Contains(t, body, `version_up_to_date{constraint="~1.18.0",latest="1.18.3",latest_raw_tag="v1.18.3",repository="foo"} 1`)
+	})
+}
+
+func TestHomebrewProvider(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"wget": {Constraint: "~1.21.0", Provider: "homebrew"},
+		},
+	}
+	var github = client.NewFakeClient(nil, fmt.Errorf("should not be called"))
+	var options = Options{
+		Homebrew: client.NewFakeClient([]client.Release{{TagName: "1.21.4"}}, nil),
+	}
+	testCollector(t, NewVersionCollector(&config, github, options), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 1")
+		require.Contains(t, body, `version_up_to_date{constraint="~1.21.0",latest="1.21.4",latest_raw_tag="1.21.4",repository="wget"} 1`)
+	})
+}
+
+func TestJSONProvider(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {
+				Constraint:   "~1.2.0",
+				Provider:     "json",
+				URL:          "https://example.com/versions",
+				VersionField: "name",
+				Headers:      map[string]string{"X-Api-Key": "secret"},
+			},
+		},
+	}
+	var github = client.NewFakeClient(nil, fmt.Errorf("should not be called"))
+	var requested []client.JSONFields
+	var options = Options{
+		JSON: func(fields client.JSONFields, headers map[string]string) client.Client {
+			require.Equal(t, map[string]string{"X-Api-Key": "secret"}, headers)
+			requested = append(requested, fields)
+			return urlClient{
+				url: "https://example.com/versions",
+				Client: client.NewFakeClient([]client.Release{
+					{TagName: "1.1.0"},
+					{TagName: "1.2.3"},
+					{TagName: "1.2.0"},
+				}, nil),
+			}
+		},
+	}
+	var collector = NewVersionCollector(&config, github, options)
+	for i := 0; i < 2; i++ {
+		testCollector(t, collector, func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, "version_up 1")
+			require.Contains(t, body, `version_up_to_date{constraint="~1.2.0",latest="1.2.3",latest_raw_tag="1.2.3",repository="foo"} 1`)
+		})
+	}
+	require.Equal(t, []client.JSONFields{{VersionField: "name"}}, requested)
+}
+
+func TestJSONProviderSharedURL(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"stable":["1.0.0"],"lts":["2.0.0"]}`)
+	}))
+	defer srv.Close()
+
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"a": {Constraint: "~1.0.0", Provider: "json", URL: srv.URL, VersionsPath: "stable"},
+			"b": {Constraint: "~2.0.0", Provider: "json", URL: srv.URL, VersionsPath: "lts"},
+		},
+	}
+	var shared = cache.New(time.Minute, time.Minute)
+	var options = Options{
+		JSON: func(fields client.JSONFields, headers map[string]string) client.Client {
+			return client.NewCachedClient(
+				client.NewJSONClient(fields, headers, client.HTTPOptions{Timeout: time.Second}),
+				shared,
+				fmt.Sprintf("json %+v %s", fields, client.HashHeaders(headers)),
+			)
+		},
+	}
+	testCollector(t, NewVersionCollector(&config, nil, options), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_up_to_date{constraint="~1.0.0",latest="1.0.0",latest_raw_tag="1.0.0",repository="a"} 1`)
+		require.Contains(t, body, `version_up_to_date{constraint="~2.0.0",latest="2.0.0",latest_raw_tag="2.0.0",repository="b"} 1`)
+	})
+}
+
+func TestServiceProviderSharedURL(t *testing.T) {
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"version":"%s"}`, r.Header.Get("X-Version"))
+	}))
+	defer srv.Close()
+
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"a": {Constraint: "~1.0.0", Provider: "service", URL: srv.URL, Headers: map[string]string{"X-Version": "1.0.0"}},
+			"b": {Constraint: "~2.0.0", Provider: "service", URL: srv.URL, Headers: map[string]string{"X-Version": "2.0.0"}},
+		},
+	}
+	var shared = cache.New(time.Minute, time.Minute)
+	var options = Options{
+		Service: func(headers map[string]string) client.Client {
+			return client.NewCachedClient(
+				client.NewServiceClient(headers, client.HTTPOptions{Timeout: time.Second}),
+				shared,
+				"service "+client.HashHeaders(headers),
+			)
+		},
+	}
+	testCollector(t, NewVersionCollector(&config, nil, options), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_up_to_date{constraint="~1.0.0",latest="1.0.0",latest_raw_tag="1.0.0",repository="a"} 1`)
+		require.Contains(t, body, `version_up_to_date{constraint="~2.0.0",latest="2.0.0",latest_raw_tag="2.0.0",repository="b"} 1`)
+	})
+}
+
+func TestServiceProviderWithoutURL(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.18.0", Provider: "service"},
+		},
+	}
+	var service = client.NewFakeClient([]client.Release{{TagName: "v1.18.3"}}, nil)
+	var options = Options{
+		Service: func(map[string]string) client.Client { return service },
+	}
+	testCollector(t, NewVersionCollector(&config, service, options), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 0")
+	})
+}
+
+// urlClient fails unless asked for the releases of url.
+type urlClient struct {
+	client.Client
+	url string
+}
+
+func (c urlClient) Releases(url string) ([]client.Release, error) {
+	if url != c.url {
+		return nil, fmt.Errorf("unexpected url: %s", url)
+	}
+	return c.Client.Releases(url)
+}
+
+func TestExecProviderCommandNotAllowed(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0", Provider: "exec", Command: "rm"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{{TagName: "1.2.4"}}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 0")
+	})
+}
+
+func TestInvalidProvider(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0", Provider: "nope"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{{TagName: "1.2.4"}}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 0")
+	})
+}
+
+func TestLenientSemver(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "version-v-1.2.3"},
+		{TagName: "1.2.2v"},
+	}, nil)
+	t.Run("strict", func(t *testing.T) {
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.NotContains(t, body, "version_up_to_date{")
+		})
+	})
+	t.Run("lenient", func(t *testing.T) {
+		testCollector(t, NewVersionCollector(&config, client, Options{LenientSemver: true}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, `version_up_to_date{constraint="~1.2.0",latest="1.2.3",latest_raw_tag="version-v-1.2.3",repository="foo"} 1`)
+		})
+	})
+}
+
+func TestExcludePrefix(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0", Source: "tags", ExcludePrefix: []string{"docs-", "infra-"}},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "docs-2.0.0"},
+		{TagName: "infra-3.1.0"},
+		{TagName: "1.2.5"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{LenientSemver: true}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_up_to_date{constraint="~1.2.0",latest="1.2.5",latest_raw_tag="1.2.5",repository="foo"} 1`)
+	})
+}
+
+func TestUpToDateStreak(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{{TagName: "v1.2.3"}}, nil)
+	var collector = NewVersionCollector(&config, client, Options{})
+	testCollector(t, collector, func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_up_to_date_streak_seconds{repository="foo"} `)
+	})
+	time.Sleep(10 * time.Millisecond)
+	require.True(t, collector.(*versionCollector).streak("foo", true) >= 10*time.Millisecond)
+
+	var settings = config.Repositories["foo"]
+	settings.Constraint = "~1.1.0"
+	config.Repositories["foo"] = settings
+	testCollector(t, collector, func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_up_to_date_streak_seconds{repository="foo"} 0`)
+	})
+
+	collector.(*versionCollector).streak("foo", true)
+	delete(config.Repositories, "foo")
+	testCollector(t, collector, func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+	})
+	require.Empty(t, collector.(*versionCollector).upToDateSince, "should forget removed repositories")
+}
+
+func TestPublishedWithin(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0", PublishedWithin: 7 * 24 * time.Hour},
+		},
+	}
+	t.Run("recent release", func(t *testing.T) {
+		var client = client.NewFakeClient([]client.Release{
+			{TagName: "v1.3.0", PublishedAt: time.Now().Add(-time.Hour)},
+			{TagName: "v1.2.0", PublishedAt: time.Now().Add(-30 * 24 * time.Hour)},
+		}, nil)
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, `version_no_recent_release{repository="foo"} 0`)
+			require.Contains(t, body, `version_up_to_date{constraint="~1.2.0",latest="1.3.0",latest_raw_tag="v1.3.0",repository="foo"} 0`)
+		})
+	})
+	t.Run("no recent release", func(t *testing.T) {
+		var client = client.NewFakeClient([]client.Release{
+			{TagName: "v1.3.0", PublishedAt: time.Now().Add(-8 * 24 * time.Hour)},
+		}, nil)
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, `version_no_recent_release{repository="foo"} 1`)
+			require.NotContains(t, body, `version_up_to_date{`)
+		})
+	})
+}
+
+func TestPublishedWithinProvider(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0", Provider: "exec", Command: "get-version", PublishedWithin: time.Hour},
+		},
+	}
+	var options = Options{
+		Commands: map[string]client.Client{
+			"get-version": client.NewFakeClient([]client.Release{{TagName: "1.2.4"}}, nil),
+		},
+	}
+	testCollector(t, NewVersionCollector(&config, nil, options), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 0")
+		require.NotContains(t, body, `version_no_recent_release{`)
+	})
+}
+
+func TestDurationEWMA(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{{TagName: "v1.2.3"}}, nil)
+	t.Run("disabled", func(t *testing.T) {
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.NotContains(t, body, `version_probe_duration_ewma_seconds{`)
+		})
+	})
+	t.Run("enabled", func(t *testing.T) {
+		testCollector(t, NewVersionCollector(&config, client, Options{DurationAlpha: 0.5}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, `version_probe_duration_ewma_seconds{repository="foo"} `)
+		})
+	})
+	t.Run("smoothing", func(t *testing.T) {
+		var collector = NewVersionCollector(&config, client, Options{DurationAlpha: 0.25}).(*versionCollector)
+		require.Equal(t, 4.0, collector.ewma("foo", 4*time.Second))
+		require.Equal(t, 3.0, collector.ewma("foo", 0))
+		require.Equal(t, 4.25, collector.ewma("foo", 8*time.Second))
+	})
+}
+
+func TestForks(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo/bar": {Constraint: "~1.2.0", Forks: []string{"fork/bar", "broken/bar", "other/bar"}},
+		},
+	}
+	var client = reposClient{
+		"foo/bar":   {{TagName: "v1.2.0"}, {TagName: "v1.1.0"}},
+		"fork/bar":  {{TagName: "v1.3.1"}, {TagName: "v1.3.0"}, {TagName: "v1.2.0"}},
+		"other/bar": {{TagName: "v1.2.5"}},
+	}
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 1")
+		require.Contains(t, body, `version_up_to_date{constraint="~1.2.0",latest="1.3.1",latest_raw_tag="v1.3.1",repository="foo/bar"} 0`)
+		require.Contains(t, body, `version_latest_fork_info{fork="fork/bar",repository="foo/bar"} 1`)
+		require.Contains(t, body, `version_releases_fetched{repository="foo/bar"} 5`)
+	})
+}
+
+func TestForksDeterministic(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo/bar": {Constraint: "~1.2.0", Forks: []string{"slow/bar", "fast/bar"}},
+		},
+	}
+	var client = delayedClient{
+		Client: reposClient{
+			"foo/bar":  {{TagName: "v1.2.0"}},
+			"slow/bar": {{TagName: "v1.3.0"}},
+			"fast/bar": {{TagName: "v1.3.0"}},
+		},
+		delays: map[string]time.Duration{"slow/bar": 50 * time.Millisecond},
+	}
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_latest_fork_info{fork="slow/bar",repository="foo/bar"} 1`)
+	})
+}
+
+// delayedClient delays the releases of some repositories.
+type delayedClient struct {
+	client.Client
+	delays map[string]time.Duration
+}
+
+func (c delayedClient) Releases(repo string) ([]client.Release, error) {
+	time.Sleep(c.delays[repo])
+	return c.Client.Releases(repo)
+}
+
+// reposClient returns the releases of each repository, and fails for the
+// unknown ones.
+type reposClient map[string][]client.Release
+
+func (c reposClient) Releases(repo string) ([]client.Release, error) {
+	releases, ok := c[repo]
+	if !ok {
+		return nil, fmt.Errorf("unknown repository: %s", repo)
+	}
+	return releases, nil
+}
+
+func (c reposClient) Tags(repo string) ([]client.Tag, error) {
+	return nil, nil
+}
+
+func (c reposClient) Changelog(repo string) (string, error) {
+	return "", nil
+}
+
+func TestOutOfConstraint(t *testing.T) {
+	var releases = []client.Release{
+		{TagName: "v2.0.0"},
+		{TagName: "v1.3.0"},
+		{TagName: "v1.2.5"},
+		{TagName: "v1.2.4"},
+		{TagName: "v1.1.0"},
+	}
+	for constraint, count := range map[string]int{
+		"~1.2.0": 2,
+		"^1.2.0": 1,
+		">= 1.0": 0,
+		"~0.9.0": 5,
+	} {
+		constraint, count := constraint, count
+		t.Run(constraint, func(t *testing.T) {
+			var config = config.Config{
+				Repositories: map[string]config.Repository{
+					"foo": {Constraint: constraint},
+				},
+			}
+			var client = client.NewFakeClient(releases, nil)
+			testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+				require.Equal(t, 200, status)
+				require.Contains(t, body, fmt.Sprintf(`version_out_of_constraint_available{repository="foo"} %d`, count))
+			})
+		})
+	}
+}
+
+func TestNextMajorPrerelease(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~2.3.0"},
+		},
+	}
+	t.Run("found", func(t *testing.T) {
+		var client = client.NewFakeClient([]client.Release{
+			{TagName: "v4.0.0-alpha.1", Draft: true},
+			{TagName: "v3.0.0-rc.2"},
+			{TagName: "v3.0.0-rc.1", Prerelease: true},
+			{TagName: "v2.4.0-rc.1"},
+			{TagName: "v2.3.1"},
+		}, nil)
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, `version_next_major_prerelease_info{repository="foo",tag="v3.0.0-rc.2",version="3.0.0-rc.2"} 1`)
+		})
+	})
+	t.Run("not found", func(t *testing.T) {
+		var client = client.NewFakeClient([]client.Release{
+			{TagName: "v2.4.0-rc.1"},
+			{TagName: "v2.3.1"},
+		}, nil)
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.NotContains(t, body, "version_next_major_prerelease_info{")
+		})
+	})
+	t.Run("unparseable prerelease", func(t *testing.T) {
+		var client = client.NewFakeClient([]client.Release{
+			{TagName: "nightly", Prerelease: true},
+			{TagName: "v3.0.0-rc.1"},
+			{TagName: "v2.3.1"},
+		}, nil)
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, "version_up 1")
+			require.Contains(t, body, `version_next_major_prerelease_info{repository="foo",tag="v3.0.0-rc.1",version="3.0.0-rc.1"} 1`)
+		})
+	})
+}
+
+func TestNextMajorPrereleasePinned(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v2.1.0-rc.1"},
+		{TagName: "v2.0.0"},
+		{TagName: "v1.2.3"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_next_major_prerelease_info{repository="foo",tag="v2.1.0-rc.1",version="2.1.0-rc.1"} 1`)
+	})
+}
+
+func TestFormatTime(t *testing.T) {
+	var local = time.FixedZone("BRT", -3*60*60)
+	require.Equal(t, "2020-08-01T13:04:05Z", formatTime(time.Date(2020, 8, 1, 10, 4, 5, 0, local)))
+}
+
+func TestLinePrerelease(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~2.3.0"},
+		},
+	}
+	t.Run("found", func(t *testing.T) {
+		var client = client.NewFakeClient([]client.Release{
+			{TagName: "v2.4.0-rc.1"},
+			{TagName: "v2.3.2-rc.2"},
+			{TagName: "v2.3.2-rc.1"},
+			{TagName: "v2.3.1"},
+			{TagName: "v2.3.1-rc.1"},
+		}, nil)
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, `version_line_prerelease_info{repository="foo",tag="v2.3.2-rc.2",version="2.3.2-rc.2"} 1`)
+		})
+	})
+	t.Run("not found", func(t *testing.T) {
+		var client = client.NewFakeClient([]client.Release{
+			{TagName: "v2.4.0-rc.1"},
+			{TagName: "v2.3.1"},
+			{TagName: "v2.3.1-rc.1"},
+		}, nil)
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.NotContains(t, body, "version_line_prerelease_info{")
+		})
+	})
+}
+
+func TestLinePrereleasePinned(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{
+		{TagName: "v1.3.1"},
+		{TagName: "v1.2.4-rc.1"},
+		{TagName: "v1.2.3"},
+	}, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_line_prerelease_info{repository="foo",tag="v1.2.4-rc.1",version="1.2.4-rc.1"} 1`)
+	})
+}
+
+func TestCollectsInFlight(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0"},
+		},
+	}
+	var upstream = &blockingClient{
+		Client:  client.NewFakeClient([]client.Release{{TagName: "v1.2.0"}}, nil),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	var collector = NewVersionCollector(&config, upstream, Options{})
+	var done = make(chan struct{})
+	go func() {
+		defer close(done)
+		var ch = make(chan prometheus.Metric, 100)
+		collector.Collect(ch)
+	}()
+	<-upstream.started
+	require.Equal(t, 1.0, testutil.ToFloat64(collectsInFlight))
+	close(upstream.release)
+	<-done
+	require.Equal(t, 0.0, testutil.ToFloat64(collectsInFlight))
+}
+
+// blockingClient waits to be released before returning releases.
+type blockingClient struct {
+	client.Client
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingClient) Releases(repo string) ([]client.Release, error) {
+	close(c.started)
+	<-c.release
+	return c.Client.Releases(repo)
+}
+
+func TestConstraintFile(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"golang/go":   {ConstraintFileURL: "https://example.com/.tool-versions", ConstraintFileTool: "golang"},
+			"nodejs/node": {ConstraintFileURL: "https://example.com/.tool-versions"},
+		},
+	}
+	var client = client.NewFakeClient([]client.Release{{TagName: "v1.21.0"}}, nil)
+	var options = Options{
+		Pins: fakePins{"https://example.com/.tool-versions#golang": "1.20.7"},
+	}
+	testCollector(t, NewVersionCollector(&config, client, options), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "version_up 0")
+		require.Contains(t, body, `version_up_to_date{constraint="1.20.7",latest="1.21.0",latest_raw_tag="v1.21.0",repository="golang/go"} 0`)
+		require.NotContains(t, body, `repository="nodejs/node"`)
+	})
+}
+
+// fakePins returns the versions pinned by url#tool.
+type fakePins map[string]string
+
+func (p fakePins) Pinned(url, tool string) (string, error) {
+	version, ok := p[url+"#"+tool]
+	if !ok {
+		return "", fmt.Errorf("%s is not pinned in %s", tool, url)
+	}
+	return version, nil
+}
+
+func TestRequire(t *testing.T) {
+	var releases = []client.Release{
+		{TagName: "v1.3.0"},
+		{TagName: "v1.2.2", Body: "notes"},
+		{TagName: "v1.2.1", Body: "notes", Assets: []client.Asset{{Name: "foo.tar.gz"}}},
+	}
+	for name, tt := range map[string]struct {
+		require []string
+		latest  string
+	}{
+		"body":            {[]string{"body"}, "1.2.2"},
+		"assets":          {[]string{"assets"}, "1.2.1"},
+		"body and assets": {[]string{"body", "assets"}, "1.2.1"},
+		"non draft":       {[]string{"non-draft"}, "1.3.0"},
+	} {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			var config = config.Config{
+				Repositories: map[string]config.Repository{
+					"foo": {Constraint: "~1.2.0", Require: tt.require},
+				},
+			}
+			var client = client.NewFakeClient(releases, nil)
+			testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+				require.Equal(t, 200, status)
+				require.Contains(t, body, fmt.Sprintf(`latest="%s"`, tt.latest))
+			})
+		})
+	}
+	t.Run("invalid", func(t *testing.T) {
+		var config = config.Config{
+			Repositories: map[string]config.Repository{
+				"foo": {Constraint: "~1.2.0", Require: []string{"stars"}},
+			},
+		}
+		var client = client.NewFakeClient(releases, nil)
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, "version_up 0")
+		})
+	})
+	t.Run("provider", func(t *testing.T) {
+		var config = config.Config{
+			Repositories: map[string]config.Repository{
+				"foo": {Constraint: "~1.2.0", Provider: "exec", Command: "get-version", Require: []string{"body"}},
+			},
+		}
+		var options = Options{
+			Commands: map[string]client.Client{
+				"get-version": client.NewFakeClient(releases, nil),
+			},
+		}
+		testCollector(t, NewVersionCollector(&config, nil, options), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, "version_up 0")
+		})
+	})
+}
+
+func TestPrefetch(t *testing.T) {
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo/bar":  {Constraint: "~1.2.0", Forks: []string{"fork/bar"}},
+			"foo/auto": {Constraint: "~1.2.0", Source: "auto"},
+			"foo/tags": {Constraint: "~1.2.0", Source: "tags"},
+			"foo/exec": {Constraint: "~1.2.0", Provider: "exec", Command: "get-version"},
+		},
+	}
+	var client = &prefetchClient{Client: client.NewFakeClient([]client.Release{{TagName: "v1.2.0"}}, nil)}
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+	})
+	require.Equal(t, [][]string{{"foo/auto", "foo/bar", "fork/bar"}}, client.prefetched)
+}
+
+// prefetchClient records the prefetched repositories.
+type prefetchClient struct {
+	client.Client
+	prefetched [][]string
+}
+
+func (c *prefetchClient) Prefetch(repos []string) {
+	c.prefetched = append(c.prefetched, repos)
+}
+
+func TestSecurityOnly(t *testing.T) {
+	var releases = []client.Release{
+		{TagName: "v1.4.0", Name: "Features"},
+		{TagName: "v1.3.1", Name: "Security fixes"},
+		{TagName: "v1.3.0"},
+		{TagName: "v1.2.1", Name: "[SECURITY] CVE-2020-1234"},
+	}
+	for name, tt := range map[string]struct {
+		settings  config.Repository
+		latest    string
+		up        int
+		available int
+	}{
+		"security only": {config.Repository{Constraint: "~1.3.0", SecurityOnly: true}, "1.3.1", 1, 0},
+		"outdated":      {config.Repository{Constraint: "~1.2.0", SecurityOnly: true}, "1.3.1", 0, 1},
+		"marker":        {config.Repository{Constraint: "~1.4.0", SecurityMarker: "features"}, "1.4.0", 1, 0},
+	} {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			var config = config.Config{
+				Repositories: map[string]config.Repository{"foo": tt.settings},
+			}
+			var client = client.NewFakeClient(releases, nil)
+			testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+				require.Equal(t, 200, status)
+				require.Contains(t, body, fmt.Sprintf(`latest="%s",latest_raw_tag="v%s",repository="foo"} %d`, tt.latest, tt.latest, tt.up))
+				require.Contains(t, body, fmt.Sprintf(`version_security_release_available{repository="foo"} %d`, tt.available))
+			})
+		})
+	}
+	t.Run("not configured", func(t *testing.T) {
+		var config = config.Config{
+			Repositories: map[string]config.Repository{"foo": {Constraint: "~1.2.0"}},
+		}
+		var client = client.NewFakeClient(releases, nil)
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.NotContains(t, body, "version_security_release_available{")
+		})
+	})
+}
+
+func TestReleaseOverdue(t *testing.T) {
+	var releases = []client.Release{
+		{TagName: "v1.2.0", PublishedAt: time.Now().Add(-10 * 24 * time.Hour)},
+	}
+	for interval, overdue := range map[time.Duration]int{
+		30 * 24 * time.Hour: 0,
+		7 * 24 * time.Hour:  1,
+	} {
+		interval, overdue := interval, overdue
+		t.Run(interval.String(), func(t *testing.T) {
+			var config = config.Config{
+				Repositories: map[string]config.Repository{
+					"foo": {Constraint: "~1.2.0", ExpectedReleaseInterval: interval},
+				},
+			}
+			var client = client.NewFakeClient(releases, nil)
+			testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+				require.Equal(t, 200, status)
+				require.Contains(t, body, fmt.Sprintf(`version_release_overdue{repository="foo"} %d`, overdue))
+			})
+		})
+	}
+	t.Run("tags", func(t *testing.T) {
+		var config = config.Config{
+			Repositories: map[string]config.Repository{
+				"foo": {Constraint: "~1.2.0", Source: "tags", ExpectedReleaseInterval: time.Hour},
+			},
+		}
+		var client = client.NewFakeClient(releases, nil)
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, "version_up 0")
+		})
+	})
+	t.Run("provider", func(t *testing.T) {
+		var config = config.Config{
+			Repositories: map[string]config.Repository{
+				"foo": {Constraint: "~1.2.0", Provider: "exec", Command: "get-version", ExpectedReleaseInterval: time.Hour},
+			},
+		}
+		var options = Options{
+			Commands: map[string]client.Client{
+				"get-version": client.NewFakeClient(releases, nil),
+			},
+		}
+		testCollector(t, NewVersionCollector(&config, nil, options), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, "version_up 0")
+			require.NotContains(t, body, "version_release_overdue{")
+		})
+	})
+}
+
+func TestReleaseOverduePublishedWithin(t *testing.T) {
+	var releases = []client.Release{
+		{TagName: "v1.3.0-rc1", Prerelease: true, PublishedAt: time.Now().Add(-time.Hour)},
+		{TagName: "v1.2.0", PublishedAt: time.Now().Add(-10 * 24 * time.Hour)},
+	}
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {
+				Constraint:              "~1.2.0",
+				PublishedWithin:         7 * 24 * time.Hour,
+				ExpectedReleaseInterval: 7 * 24 * time.Hour,
+			},
+		},
+	}
+	var client = client.NewFakeClient(releases, nil)
+	testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, `version_no_recent_release{repository="foo"} 1`)
+		require.Contains(t, body, `version_release_overdue{repository="foo"} 1`)
+	})
+}
+
+func TestDownloadCount(t *testing.T) {
+	var releases = []client.Release{
+		{TagName: "v1.2.1", Assets: []client.Asset{{Name: "foo.tar.gz", DownloadCount: 10}, {Name: "foo.zip", DownloadCount: 5}}},
+		{TagName: "v1.2.0", Assets: []client.Asset{{Name: "foo.tar.gz", DownloadCount: 100}}},
+	}
+	var config = config.Config{
+		Repositories: map[string]config.Repository{
+			"foo": {Constraint: "~1.2.0"},
+		},
+	}
+	var client = client.NewFakeClient(releases, nil)
+	t.Run("enabled", func(t *testing.T) {
+		testCollector(t, NewVersionCollector(&config, client, Options{DownloadCount: true}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.Contains(t, body, `version_latest_release_download_count{repository="foo"} 15`)
+		})
+	})
+	t.Run("disabled", func(t *testing.T) {
+		testCollector(t, NewVersionCollector(&config, client, Options{}), func(t *testing.T, status int, body string) {
+			require.Equal(t, 200, status)
+			require.NotContains(t, body, "version_latest_release_download_count")
+		})
+	})
+}
+
 func testCollector(t *testing.T, collector prometheus.Collector, checker func(t *testing.T, status int, body string)) {
 	var registry = prometheus.NewRegistry()
 	registry.MustRegister(collector)
@@ -1,18 +1,84 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/prometheus/common/log"
 	yaml "gopkg.in/yaml.v2"
 )
 
+// mutex guards the loaded configs, which are reloaded while being served.
+// nolint: gochecknoglobals
+var mutex sync.RWMutex
+
 // Config struct representing the config file.
 type Config struct {
-	Repositories map[string]string `yaml:"repositories"`
+	Repositories map[string]Repository `yaml:"repositories" json:"repositories"`
+
+	// Hash is a short hash of the loaded config file contents.
+	Hash string `yaml:"-" json:"hash"`
+}
+
+// Repository struct representing the settings of a single repository.
+type Repository struct {
+	Constraint string `yaml:"constraint" json:"constraint,omitempty"`
+
+	ConstraintFileURL  string `yaml:"constraint_file_url" json:"constraint_file_url,omitempty"`
+	ConstraintFileTool string `yaml:"constraint_file_tool" json:"constraint_file_tool,omitempty"`
+
+	Provider string `yaml:"provider" json:"provider,omitempty"`
+	Command  string `yaml:"command" json:"command,omitempty"`
+	URL      string `yaml:"url" json:"url,omitempty"`
+	Source   string `yaml:"source" json:"source,omitempty"`
+	Mode     string `yaml:"mode" json:"mode,omitempty"`
+
+	Headers map[string]string `yaml:"headers" json:"headers,omitempty"`
+
+	VersionsPath    string `yaml:"versions_path" json:"versions_path,omitempty"`
+	VersionField    string `yaml:"version_field" json:"version_field,omitempty"`
+	PrereleaseField string `yaml:"prerelease_field" json:"prerelease_field,omitempty"`
+
+	ReleaseNameRegex string `yaml:"release_name_regex" json:"release_name_regex,omitempty"`
+	MinVersion       string `yaml:"min_version" json:"min_version,omitempty"`
+	MaxVersion       string `yaml:"max_version" json:"max_version,omitempty"`
+	Label            string `yaml:"label" json:"label,omitempty"`
+	LabelField       string `yaml:"label_field" json:"label_field,omitempty"`
+
+	ExcludePrefix []string `yaml:"exclude_prefix" json:"exclude_prefix,omitempty"`
+	Forks         []string `yaml:"forks" json:"forks,omitempty"`
+
+	PublishedWithin         time.Duration `yaml:"published_within" json:"published_within,omitempty"`
+	ExpectedReleaseInterval time.Duration `yaml:"expected_release_interval" json:"expected_release_interval,omitempty"`
+
+	Require []string `yaml:"require" json:"require,omitempty"`
+
+	SecurityOnly   bool   `yaml:"security_only" json:"security_only,omitempty"`
+	SecurityMarker string `yaml:"security_marker" json:"security_marker,omitempty"`
+
+	CompareBuildMetadata bool `yaml:"compare_build_metadata" json:"compare_build_metadata,omitempty"`
+}
+
+// UnmarshalYAML allows a repository to be declared either as a plain
+// constraint string or as a map of settings.
+func (r *Repository) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var constraint string
+	if err := unmarshal(&constraint); err == nil {
+		*r = Repository{Constraint: constraint}
+		return nil
+	}
+	type plain Repository
+	return unmarshal((*plain)(r))
 }
 
 func doLoad(file string, config *Config) error {
@@ -24,10 +90,89 @@ func doLoad(file string, config *Config) error {
 	if err := yaml.Unmarshal(bts, &newConfig); err != nil {
 		return err
 	}
+	for name, repository := range newConfig.Repositories {
+		// the other providers would silently ignore them
+		if len(repository.Headers) > 0 && repository.Provider != "service" && repository.Provider != "json" {
+			return fmt.Errorf("%s: headers are only supported by the service and json providers", name)
+		}
+		// expanded once here, so secrets don't need to be in the file
+		for header, value := range repository.Headers {
+			repository.Headers[header] = os.ExpandEnv(value)
+		}
+	}
+	newConfig.Hash = hash(bts)
+	mutex.Lock()
+	defer mutex.Unlock()
 	*config = newConfig
 	return nil
 }
 
+func hash(bts []byte) string {
+	var sum = sha256.Sum256(bts)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// Handler returns a handler that serves the effective configuration as JSON:
+// the given flags, which must already be redacted, and the loaded config,
+// with secrets redacted.
+func Handler(config *Config, flags map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.RLock()
+		var current = redacted(*config)
+		mutex.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(struct {
+			Flags  map[string]string `json:"flags"`
+			Config Config            `json:"config"`
+		}{flags, current}); err != nil {
+			log.Errorf("failed to encode config: %s", err)
+		}
+	})
+}
+
+// redacted returns a copy of the config without header values nor url
+// credentials and query values, which usually hold secrets.
+func redacted(config Config) Config {
+	var repositories = make(map[string]Repository, len(config.Repositories))
+	for name, repository := range config.Repositories {
+		repository.URL = redactedURL(repository.URL)
+		repository.ConstraintFileURL = redactedURL(repository.ConstraintFileURL)
+		if len(repository.Headers) > 0 {
+			var headers = make(map[string]string, len(repository.Headers))
+			for header := range repository.Headers {
+				headers[header] = "<redacted>"
+			}
+			repository.Headers = headers
+		}
+		repositories[name] = repository
+	}
+	config.Repositories = repositories
+	return config
+}
+
+// redactedURL returns the url without its userinfo nor query values. Urls
+// that can't be parsed are redacted entirely.
+func redactedURL(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<redacted>"
+	}
+	if u.User != nil {
+		u.User = url.User("redacted")
+	}
+	if u.RawQuery != "" {
+		var query = u.Query()
+		for key := range query {
+			query[key] = []string{"redacted"}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
 // Load loads a config file and reloads it if a SIGHUP is received.
 func Load(file string, config *Config, onReload func()) {
 	if err := doLoad(file, config); err != nil {
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
 
 	"github.com/alecthomas/kingpin"
 	"github.com/caarlos0/version_exporter/client"
@@ -18,11 +24,29 @@ import (
 var (
 	bind       = kingpin.Flag("bind", "addr to bind the server").Default(":9333").String()
 	debug      = kingpin.Flag("debug", "show debug logs").Default("false").Bool()
+	bodySize   = kingpin.Flag("debug.body-size", "max bytes of failed upstream response bodies to log in debug mode").Default("1024").Int()
 	token      = kingpin.Flag("github.token", "github token").Envar("GITHUB_TOKEN").String()
 	configFile = kingpin.Flag("config.file", "config file").Default("config.yaml").ExistingFile()
 	interval   = kingpin.Flag("refresh.interval", "time between refreshes with github api").Default("15m").Duration()
+	onLimit    = kingpin.Flag("github.on-rate-limit", "whether to fail or wait when rate limited by github api").Default(client.RateLimitFail).Enum(client.RateLimitFail, client.RateLimitWait)
+	graphql    = kingpin.Flag("github.graphql", "get the releases of many repositories at once with the github graphql api, which requires a token").Default("false").Bool()
+	limitWait  = kingpin.Flag("github.rate-limit-max-wait", "max time to wait for the github api rate limit to reset").Default("5s").Duration()
+	perHost    = kingpin.Flag("http.max-per-host", "max concurrent requests to a single host, 0 disables the limit").Default("4").Int()
+	redirects  = kingpin.Flag("http.max-redirects", "max redirects to follow on upstream requests, 0 disables following them").Default("5").Int()
+	commands   = kingpin.Flag("exec.command", "command the exec provider is allowed to run, can be repeated").Strings()
+	timeout    = kingpin.Flag("exec.timeout", "max time to wait for an exec provider command").Default("10s").Duration()
+	svcTimeout = kingpin.Flag("service.timeout", "max time to wait for a service provider endpoint").Default("10s").Duration()
+	brewTime   = kingpin.Flag("homebrew.timeout", "max time to wait for the homebrew formulae api").Default("10s").Duration()
+	jsonTime   = kingpin.Flag("json.timeout", "max time to wait for a json provider api").Default("10s").Duration()
+	pinsTime   = kingpin.Flag("constraint-file.timeout", "max time to wait for a constraint file").Default("10s").Duration()
+	lenient    = kingpin.Flag("lenient-semver", "extract versions from malformed tags instead of ignoring them").Default("false").Bool()
+	alpha      = kingpin.Flag("duration.ewma-alpha", "smoothing factor of the per repository collect duration moving average, 0 disables it").Default("0").Float64()
+	downloads  = kingpin.Flag("github.download-count", "expose the download count of the latest release assets").Default("false").Bool()
+	showConfig = kingpin.Flag("config.endpoint", "serve the effective configuration, with secrets redacted, on /config").Default("false").Bool()
+	inMaint    = kingpin.Flag("maintenance", "start in maintenance mode, serving last known results without calling upstream, toggled with SIGUSR1").Default("false").Bool()
 
 	version = "dev"
+	date    = "unknown"
 )
 
 func main() {
@@ -42,12 +66,82 @@ func main() {
 	config.Load(*configFile, &cfg, func() {
 		log.Debug("flushing cache...")
 		cache.Flush()
+		client.ResetMoved()
 	})
 
-	var client = client.NewCachedClient(client.NewClient(*token), cache)
+	var maintenance client.Maintenance
+	maintenance.Set(*inMaint)
+	var maintenanceCh = make(chan os.Signal, 1)
+	signal.Notify(maintenanceCh, syscall.SIGUSR1)
+	go func() {
+		for range maintenanceCh {
+			maintenance.Set(!maintenance.Enabled())
+			log.Infof("maintenance mode: %v", maintenance.Enabled())
+		}
+	}()
 
-	prometheus.MustRegister(collector.NewVersionCollector(&cfg, client))
+	var limiter = client.NewHostLimiter(*perHost)
+	var options = collector.Options{
+		Commands:      map[string]client.Client{},
+		LenientSemver: *lenient,
+		DurationAlpha: *alpha,
+		DownloadCount: *downloads,
+		Service: func(headers map[string]string) client.Client {
+			return client.NewCachedClient(
+				client.NewMaintenanceClient(client.NewServiceClient(headers, httpOptions(*svcTimeout, limiter)), &maintenance),
+				cache,
+				"service "+client.HashHeaders(headers),
+			)
+		},
+		Homebrew: client.NewCachedClient(
+			client.NewMaintenanceClient(client.NewHomebrewClient(httpOptions(*brewTime, limiter)), &maintenance),
+			cache,
+			"homebrew",
+		),
+		Pins: client.NewCachedPins(
+			client.NewMaintenancePins(client.NewPins(httpOptions(*pinsTime, limiter)), &maintenance),
+			cache,
+		),
+		JSON: func(fields client.JSONFields, headers map[string]string) client.Client {
+			return client.NewCachedClient(
+				client.NewMaintenanceClient(client.NewJSONClient(fields, headers, httpOptions(*jsonTime, limiter)), &maintenance),
+				cache,
+				fmt.Sprintf("json %+v %s", fields, client.HashHeaders(headers)),
+			)
+		},
+	}
+	for _, command := range *commands {
+		options.Commands[command] = client.NewCachedClient(
+			client.NewMaintenanceClient(client.NewExecClient(command, *timeout), &maintenance),
+			cache,
+			"exec "+command,
+		)
+	}
+
+	var client = client.NewCachedClient(client.NewMaintenanceClient(client.NewClient(client.GithubOptions{
+		Token:         *token,
+		OnRateLimit:   *onLimit,
+		MaxWait:       *limitWait,
+		Limiter:       limiter,
+		DebugBodySize: debugBodySize(),
+		MaxRedirects:  *redirects,
+		GraphQL:       *graphql,
+	}), &maintenance), cache, "")
+
+	prometheus.MustRegister(collector.NewVersionCollector(&cfg, client, options))
 	http.Handle("/metrics", promhttp.Handler())
+	if *showConfig {
+		http.Handle("/config", config.Handler(&cfg, redactedFlags()))
+	}
+
+	http.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"version":    version,
+			"go_version": runtime.Version(),
+			"build_date": date,
+		})
+	})
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(
@@ -67,3 +161,42 @@ func main() {
 		log.Fatalf("error starting server: %s", err)
 	}
 }
+
+// debugBodySize returns how much of failed upstream response bodies to log,
+// which is nothing when not in debug mode, so they are not kept at all.
+func debugBodySize() int {
+	if !*debug {
+		return 0
+	}
+	return *bodySize
+}
+
+// httpOptions returns the options of a provider http client with the given
+// timeout and limiter.
+func httpOptions(timeout time.Duration, limiter *client.HostLimiter) client.HTTPOptions {
+	return client.HTTPOptions{
+		Timeout:       timeout,
+		DebugBodySize: debugBodySize(),
+		MaxRedirects:  *redirects,
+		Limiter:       limiter,
+	}
+}
+
+// redactedFlags returns the values of all flags, with secrets redacted.
+func redactedFlags() map[string]string {
+	var secrets = map[string]bool{
+		"github.token": true,
+	}
+	var flags = map[string]string{}
+	for _, flag := range kingpin.CommandLine.Model().Flags {
+		if flag.Hidden {
+			continue
+		}
+		var value = flag.Value.String()
+		if secrets[flag.Name] && value != "" {
+			value = "<redacted>"
+		}
+		flags[flag.Name] = value
+	}
+	return flags
+}